@@ -11,6 +11,22 @@ const (
 	vectorDmpSetProperty = 0x2
 )
 
+const (
+	// MinUniverse is the lowest universe that can carry data
+	MinUniverse = 1
+	// MaxUniverse is the highest universe that can carry data. The universes above are reserved,
+	// except for the DiscoveryUniverse.
+	MaxUniverse = 63999
+)
+
+const (
+	// StartCodeDMX is the start code for normal DMX data
+	StartCodeDMX = 0x00
+	// StartCodePerAddressPriority is the start code for packets that carry a priority per slot
+	// instead of DMX data. A value of 0 means that the source does not control the slot.
+	StartCodePerAddressPriority = 0xDD
+)
+
 var constHeader = []byte{0, 0x10, 0, 0, 0x41, 0x53,
 	0x43, 0x2d, 0x45, 0x31, 0x2e, 0x31, 0x37, 0x00, 0x00, 0x00}
 
@@ -44,23 +60,71 @@ func NewDataPacket() DataPacket {
 	return p
 }
 
-// NewDataPacketRaw creates a new DataPacket based on the given raw bytes
+// NewDataPacketRaw creates a new DataPacket based on the given raw bytes.
+// All layers of the packet are validated, if the bytes are not a valid data packet, a *PacketError
+// is returned.
 func NewDataPacketRaw(raw []byte) (DataPacket, error) {
-	var p DataPacket
+	if len(raw) > 638 {
+		return DataPacket{}, newPacketError(ErrInvalidLength, "length has to be in range [126-638], was %v", len(raw))
+	}
+	//make a copy of the slice, we do not want to use a reference. The rest of the 638 bytes is 0
+	data := make([]byte, 638)
+	n := copy(data, raw)
+	return newDataPacketView(data, n)
+}
+
+// newDataPacketView creates a DataPacket that uses the given 638 byte long buffer without copying
+// it. n is the number of bytes that were received, all bytes after n are set to 0. The packet must
+// not be used anymore, if the buffer is reused.
+func newDataPacketView(buf []byte, n int) (DataPacket, error) {
+	if n > len(buf) {
+		return DataPacket{}, newPacketError(ErrInvalidLength, "max length is %v, was %v", len(buf), n)
+	}
+	if err := checkDataPacket(buf[:n]); err != nil {
+		return DataPacket{}, err
+	}
+	for i := n; i < len(buf); i++ {
+		buf[i] = 0
+	}
+	return DataPacket{
+		data:   buf,
+		length: uint16(n),
+	}, nil
+}
+
+// checkDataPacket validates all layers of the raw bytes of a data packet
+func checkDataPacket(raw []byte) error {
 	//Check the length of the raw bytes
-	if len(raw) < 126 {
-		return p, fmt.Errorf("The given raw bytes are too short! Min length is 126 was %v", len(raw))
+	if len(raw) < 126 || len(raw) > 638 {
+		return newPacketError(ErrInvalidLength, "length has to be in range [126-638], was %v", len(raw))
+	}
+	if err := checkRootLayer(raw, vectorRootE131Data); err != nil {
+		return err
+	}
+	if err := checkFlagsLength(raw, 38, "framing"); err != nil {
+		return err
 	}
-	p = NewDataPacket()
-	//Make the array 638 long
-	if len(raw) < 638 { //Add 0 if too short
-		raw = append(raw, make([]byte, 638-len(raw))...)
-	} else if len(raw) > 638 { //cut off the last bits if too long
-		raw = raw[:638]
+	if v := getAsUint32(raw[40:44]); v != vectorE131DataPacket {
+		return newPacketError(ErrInvalidVector, "framing vector was %v, expected %v", v, vectorE131DataPacket)
 	}
-	p.data = append([]byte(nil), raw...) //make a copy of the slice, we do not want to use a reference
-	p.length = uint16(getAsUint32(raw[123:125]) + 125)
-	return p, nil
+	if universe := getAsUint32(raw[113:115]); universe < MinUniverse || universe > MaxUniverse {
+		return newPacketError(ErrInvalidUniverse, "has to be in range [%v-%v], was %v",
+			MinUniverse, MaxUniverse, universe)
+	}
+	if err := checkFlagsLength(raw, 115, "DMP"); err != nil {
+		return err
+	}
+	if raw[117] != vectorDmpSetProperty {
+		return newPacketError(ErrInvalidVector, "DMP vector was %v, expected %v", raw[117], vectorDmpSetProperty)
+	}
+	if raw[118] != 0xa1 || getAsUint32(raw[119:121]) != 0 || getAsUint32(raw[121:123]) != 1 {
+		return newPacketError(ErrInvalidDMP, "address type %#x, first address %v and increment %v, expected 0xa1, 0 and 1",
+			raw[118], getAsUint32(raw[119:121]), getAsUint32(raw[121:123]))
+	}
+	if count := int(getAsUint32(raw[123:125])); count != len(raw)-125 {
+		return newPacketError(ErrInvalidDMP, "property value count was %v, expected %v", count, len(raw)-125)
+	}
+	return nil
 }
 
 // Set the FAL values in the byte slice according to the length
@@ -83,6 +147,10 @@ func (d *DataPacket) setFAL(length uint16) {
 
 // replace everything starting from the start index in the DataPacket with the given replacement
 func (d *DataPacket) replace(startIndex int, replacement []byte) {
+	if startIndex+len(replacement) <= len(d.data) {
+		copy(d.data[startIndex:], replacement)
+		return
+	}
 	d.data = append(d.data[:startIndex],
 		append(replacement, d.data[len(replacement)+startIndex:]...)...)
 }
@@ -97,6 +165,16 @@ func (d *DataPacket) copy() DataPacket {
 	}
 }
 
+// set copies the given packet into this packet. The existing buffer is reused, if it is big enough.
+func (d *DataPacket) set(p DataPacket) {
+	if cap(d.data) < len(p.data) {
+		d.data = make([]byte, len(p.data))
+	}
+	d.data = d.data[:len(p.data)]
+	copy(d.data, p.data)
+	d.length = p.length
+}
+
 // SetCID sets the CID unique identifier
 func (d *DataPacket) SetCID(cid [16]byte) {
 	d.replace(22, cid[0:16])
@@ -245,6 +323,16 @@ func (d *DataPacket) Data() []byte {
 	return d.data[126:d.length]
 }
 
+// RootVector returns the vector of the root layer
+func (d *DataPacket) RootVector() uint32 {
+	return getAsUint32(d.data[18:22])
+}
+
+// FramingVector returns the vector of the framing layer
+func (d *DataPacket) FramingVector() uint32 {
+	return getAsUint32(d.data[40:44])
+}
+
 func (d *DataPacket) getBytes() []byte {
 	return d.data[:d.length]
 }
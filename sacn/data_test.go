@@ -2,6 +2,7 @@ package sacn
 
 import (
 	"bytes"
+	"errors"
 	"math/rand"
 	"testing"
 )
@@ -148,3 +149,90 @@ func TestSetData(t *testing.T) {
 		t.Errorf("DMX data was not set or getted properly! Was: %v \nShouldbe: %v", p.Data(), i)
 	}
 }
+
+func TestNewDataPacketRaw(t *testing.T) {
+	p := newTestPacket(1, 0, []byte{1, 2})
+	raw := p.getBytes()
+	parsed, err := NewDataPacketRaw(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.data) != 638 || !bytes.Equal(parsed.Data(), []byte{1, 2}) {
+		t.Errorf("Wrong packet! Length: %v Data: %v", len(parsed.data), parsed.Data())
+	}
+	raw[126] = 9
+	if parsed.Data()[0] != 1 {
+		t.Error("The raw bytes were not copied")
+	}
+	if _, err := NewDataPacketRaw(raw[:125]); err == nil {
+		t.Error("Err was nil! The raw bytes are too short")
+	}
+}
+
+func TestDataPacketView(t *testing.T) {
+	buf := make([]byte, 638)
+	for i := range buf {
+		buf[i] = 0xFF //old data of a previous packet
+	}
+	p := newTestPacket(1, 0, []byte{1, 2})
+	n := copy(buf, p.getBytes())
+	view, err := newDataPacketView(buf, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[n:], make([]byte, 638-n)) {
+		t.Error("The bytes after n were not set to 0")
+	}
+	buf[126] = 9
+	if view.Data()[0] != 9 {
+		t.Error("The view should use the buffer without copying")
+	}
+}
+
+func BenchmarkNewDataPacketRaw(b *testing.B) {
+	p := newTestPacket(1, 0, make([]byte, 512))
+	raw := p.getBytes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewDataPacketRaw(raw)
+	}
+}
+
+func TestNewDataPacketRawValidation(t *testing.T) {
+	valid := newTestPacket(1, 0, []byte{1, 2})
+	tests := []struct {
+		name   string
+		modify func(raw []byte) []byte
+		err    error
+	}{
+		{"too short", func(raw []byte) []byte { return raw[:125] }, ErrInvalidLength},
+		{"too long", func(raw []byte) []byte { return append(raw, make([]byte, 640-len(raw))...) }, ErrInvalidLength},
+		{"preamble", func(raw []byte) []byte { raw[1] = 0x11; return raw }, ErrInvalidPreamble},
+		{"postamble", func(raw []byte) []byte { raw[3] = 1; return raw }, ErrInvalidPreamble},
+		{"identifier", func(raw []byte) []byte { copy(raw[4:], "Art-Net"); return raw }, ErrInvalidIdentifier},
+		{"root flags", func(raw []byte) []byte { raw[16] &= 0x0F; return raw }, ErrInvalidFlagsLength},
+		{"root vector", func(raw []byte) []byte { raw[21] = 8; return raw }, ErrInvalidVector},
+		{"framing length", func(raw []byte) []byte { raw[39]++; return raw }, ErrInvalidFlagsLength},
+		{"framing vector", func(raw []byte) []byte { raw[43] = 1; return raw }, ErrInvalidVector},
+		{"universe 0", func(raw []byte) []byte { raw[113], raw[114] = 0, 0; return raw }, ErrInvalidUniverse},
+		{"universe 64000", func(raw []byte) []byte { raw[113], raw[114] = 0xFA, 0; return raw }, ErrInvalidUniverse},
+		{"DMP length", func(raw []byte) []byte { raw[116]--; return raw }, ErrInvalidFlagsLength},
+		{"DMP vector", func(raw []byte) []byte { raw[117] = 1; return raw }, ErrInvalidVector},
+		{"address type", func(raw []byte) []byte { raw[118] = 0xa2; return raw }, ErrInvalidDMP},
+		{"first address", func(raw []byte) []byte { raw[120] = 1; return raw }, ErrInvalidDMP},
+		{"increment", func(raw []byte) []byte { raw[122] = 2; return raw }, ErrInvalidDMP},
+		{"property count", func(raw []byte) []byte { raw[124]++; return raw }, ErrInvalidDMP},
+		{"datagram longer than FAL", func(raw []byte) []byte { return append(raw, 0, 0) }, ErrInvalidFlagsLength},
+	}
+	for _, test := range tests {
+		raw := test.modify(append([]byte(nil), valid.getBytes()...))
+		_, err := NewDataPacketRaw(raw)
+		var packetErr *PacketError
+		if !errors.As(err, &packetErr) || !errors.Is(err, test.err) {
+			t.Errorf("%v: Wrong error! Was: %v; Should've been: %v", test.name, err, test.err)
+		}
+	}
+	if _, err := NewDataPacketRaw(valid.getBytes()); err != nil {
+		t.Errorf("Unexpected error for a valid packet: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package sacn
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	vectorE131ExtendedDiscovery         = 2 //VECTOR_E131_EXTENDED_DISCOVERY
+	vectorUniverseDiscoveryUniverseList = 1 //VECTOR_UNIVERSE_DISCOVERY_UNIVERSE_LIST
+	// DiscoveryUniverse is the universe on which the universe discovery packets are sent
+	DiscoveryUniverse = 64214
+	// the interval in which a source sends out its universe discovery packets
+	discoveryInterval = time.Second * 10
+	// the maximum number of universes that fit into one discovery packet
+	discoveryPageSize = 512
+	// the length of a discovery packet without any universes
+	discoveryHeaderLength = 120
+)
+
+// DiscoveryPacket is an E1.31 universe discovery packet. A source sends out its active universes
+// with these packets. If there are more than 512 universes, the list is split up into multiple pages.
+type DiscoveryPacket struct {
+	data []byte
+}
+
+// NewDiscoveryPacket creates a new DiscoveryPacket with all constant fields set and no universes
+func NewDiscoveryPacket() DiscoveryPacket {
+	p := DiscoveryPacket{make([]byte, discoveryHeaderLength)}
+	//Set constants: at index [0;16[
+	copy(p.data[0:16], constHeader)
+	//Set vectors:
+	copy(p.data[18:22], getAsBytes32(vectorRootE131Extended))
+	copy(p.data[40:44], getAsBytes32(vectorE131ExtendedDiscovery))
+	copy(p.data[114:118], getAsBytes32(vectorUniverseDiscoveryUniverseList))
+	p.setFAL(discoveryHeaderLength)
+	return p
+}
+
+// NewDiscoveryPacketRaw creates a new DiscoveryPacket based on the given raw bytes. If the bytes are
+// not a valid universe discovery packet, a *PacketError is returned.
+func NewDiscoveryPacketRaw(raw []byte) (DiscoveryPacket, error) {
+	var p DiscoveryPacket
+	if len(raw) < discoveryHeaderLength || len(raw) > discoveryHeaderLength+2*discoveryPageSize ||
+		(len(raw)-discoveryHeaderLength)%2 != 0 {
+		return p, newPacketError(ErrInvalidLength, "length has to be %v plus 2 bytes per universe, was %v",
+			discoveryHeaderLength, len(raw))
+	}
+	if err := checkRootLayer(raw, vectorRootE131Extended); err != nil {
+		return p, err
+	}
+	if err := checkFlagsLength(raw, 38, "framing"); err != nil {
+		return p, err
+	}
+	if v := getAsUint32(raw[40:44]); v != vectorE131ExtendedDiscovery {
+		return p, newPacketError(ErrInvalidVector, "framing vector was %v, expected %v", v, vectorE131ExtendedDiscovery)
+	}
+	//the length of the universe list is determined by the FAL of the discovery layer
+	if err := checkFlagsLength(raw, 112, "discovery"); err != nil {
+		return p, err
+	}
+	if v := getAsUint32(raw[114:118]); v != vectorUniverseDiscoveryUniverseList {
+		return p, newPacketError(ErrInvalidVector, "discovery vector was %v, expected %v",
+			v, vectorUniverseDiscoveryUniverseList)
+	}
+	p.data = make([]byte, len(raw))
+	copy(p.data, raw)
+	return p, nil
+}
+
+// newDiscoveryPackets creates all pages that are needed to announce the given universes
+func newDiscoveryPackets(cid [16]byte, sourceName string, universes []uint16) []DiscoveryPacket {
+	sorted := make([]uint16, len(universes))
+	copy(sorted, universes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	lastPage := len(sorted) / discoveryPageSize
+	if len(sorted) > 0 && len(sorted)%discoveryPageSize == 0 {
+		lastPage--
+	}
+	packets := make([]DiscoveryPacket, 0, lastPage+1)
+	for page := 0; page <= lastPage; page++ {
+		end := (page + 1) * discoveryPageSize
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		p := NewDiscoveryPacket()
+		p.SetCID(cid)
+		p.SetSourceName(sourceName)
+		p.SetPage(byte(page))
+		p.SetLastPage(byte(lastPage))
+		p.SetUniverses(sorted[page*discoveryPageSize : end])
+		packets = append(packets, p)
+	}
+	return packets
+}
+
+// Set the FAL values in the byte slice according to the length
+// Note: Length is the length of the whole message!
+func (d *DiscoveryPacket) setFAL(length uint16) {
+	rootFAL := calculateFal(length - 16)
+	copy(d.data[16:18], rootFAL[:])
+	framingFAL := calculateFal(length - 38)
+	copy(d.data[38:40], framingFAL[:])
+	discoveryFAL := calculateFal(length - 112)
+	copy(d.data[112:114], discoveryFAL[:])
+}
+
+// SetCID sets the CID unique identifier
+func (d *DiscoveryPacket) SetCID(cid [16]byte) {
+	copy(d.data[22:38], cid[:])
+}
+
+// CID returns the cid that is set for this object
+func (d *DiscoveryPacket) CID() [16]byte {
+	tmpArray := [16]byte{}
+	copy(tmpArray[:], d.data[22:38])
+	return tmpArray
+}
+
+// SetSourceName sets the source name field to the given string values.
+// Note that only the first 64 characters are used!
+func (d *DiscoveryPacket) SetSourceName(s string) {
+	b := [64]byte{}
+	copy(b[:], []byte(s))
+	copy(d.data[44:108], b[:])
+}
+
+// SourceName returns the stored source name. Note that the source name max length is 64!
+func (d *DiscoveryPacket) SourceName() string {
+	i := 44 //the ending index for the string, because it is 0 terminated
+	for i < 108 && d.data[i] != 0 {
+		i++
+	}
+	return string(d.data[44:i])
+}
+
+// SetPage sets the number of this page. The first page is 0
+func (d *DiscoveryPacket) SetPage(page byte) {
+	d.data[118] = page
+}
+
+// Page returns the number of this page
+func (d *DiscoveryPacket) Page() byte {
+	return d.data[118]
+}
+
+// SetLastPage sets the number of the last page
+func (d *DiscoveryPacket) SetLastPage(page byte) {
+	d.data[119] = page
+}
+
+// LastPage returns the number of the last page
+func (d *DiscoveryPacket) LastPage() byte {
+	return d.data[119]
+}
+
+// SetUniverses sets the list of universes for this page. The universes have to be sorted in
+// ascending order. Note that only the first 512 universes are used!
+func (d *DiscoveryPacket) SetUniverses(universes []uint16) {
+	if len(universes) > discoveryPageSize {
+		universes = universes[:discoveryPageSize]
+	}
+	length := discoveryHeaderLength + 2*len(universes)
+	data := make([]byte, length)
+	copy(data, d.data[:discoveryHeaderLength])
+	for i, univ := range universes {
+		copy(data[discoveryHeaderLength+2*i:], getAsBytes16(univ))
+	}
+	d.data = data
+	d.setFAL(uint16(length))
+}
+
+// Universes returns the list of universes on this page
+func (d *DiscoveryPacket) Universes() []uint16 {
+	list := make([]uint16, (len(d.data)-discoveryHeaderLength)/2)
+	for i := range list {
+		index := discoveryHeaderLength + 2*i
+		list[i] = uint16(getAsUint32(d.data[index : index+2]))
+	}
+	return list
+}
+
+// RootVector returns the vector of the root layer
+func (d *DiscoveryPacket) RootVector() uint32 {
+	return getAsUint32(d.data[18:22])
+}
+
+// FramingVector returns the vector of the framing layer
+func (d *DiscoveryPacket) FramingVector() uint32 {
+	return getAsUint32(d.data[40:44])
+}
+
+// Sequence always returns 0, because discovery packets do not have a sequence number. It is only
+// implemented for the Packet interface.
+func (d *DiscoveryPacket) Sequence() byte {
+	return 0
+}
+
+func (d *DiscoveryPacket) getBytes() []byte {
+	return d.data
+}
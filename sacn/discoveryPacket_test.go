@@ -0,0 +1,115 @@
+package sacn
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewDiscoveryPacket(t *testing.T) {
+	p := NewDiscoveryPacket()
+	if len(p.getBytes()) != 120 {
+		t.Errorf("Wrong length! Was: %v; Should've been: %v", len(p.getBytes()), 120)
+	}
+	if getAsUint32(p.data[18:22]) != vectorRootE131Extended ||
+		getAsUint32(p.data[40:44]) != vectorE131ExtendedDiscovery ||
+		getAsUint32(p.data[114:118]) != vectorUniverseDiscoveryUniverseList {
+		t.Error("Wrong vectors in discovery packet!")
+	}
+	p.SetUniverses([]uint16{1, 2, 0x1234})
+	if len(p.getBytes()) != 126 {
+		t.Errorf("Wrong length! Was: %v; Should've been: %v", len(p.getBytes()), 126)
+	}
+	if !bytes.Equal(p.data[16:18], []byte{0x70, 126 - 16}) ||
+		!bytes.Equal(p.data[38:40], []byte{0x70, 126 - 38}) ||
+		!bytes.Equal(p.data[112:114], []byte{0x70, 126 - 112}) {
+		t.Errorf("Wrong FAL values! Was: %v %v %v", p.data[16:18], p.data[38:40], p.data[112:114])
+	}
+	if !bytes.Equal(p.data[120:126], []byte{0, 1, 0, 2, 0x12, 0x34}) {
+		t.Errorf("Wrong universe list! Was: %v", p.data[120:126])
+	}
+}
+
+func TestDiscoveryPacketFields(t *testing.T) {
+	p := NewDiscoveryPacket()
+	cid := [16]byte{1, 2, 3}
+	p.SetCID(cid)
+	p.SetSourceName("discovery source")
+	p.SetPage(1)
+	p.SetLastPage(2)
+	p.SetUniverses([]uint16{3, 4, 5})
+	if p.CID() != cid || p.SourceName() != "discovery source" || p.Page() != 1 || p.LastPage() != 2 {
+		t.Errorf("Wrong output! Was: %v %v %v %v", p.CID(), p.SourceName(), p.Page(), p.LastPage())
+	}
+	if u := p.Universes(); len(u) != 3 || u[0] != 3 || u[2] != 5 {
+		t.Errorf("Wrong universes! Was: %v", u)
+	}
+	//setting universes must not change the other fields
+	if p.SourceName() != "discovery source" {
+		t.Errorf("Source name was changed by SetUniverses! Was: %v", p.SourceName())
+	}
+}
+
+func TestNewDiscoveryPackets(t *testing.T) {
+	universes := make([]uint16, 1030)
+	for i := range universes {
+		universes[i] = uint16(len(universes) - i)
+	}
+	packets := newDiscoveryPackets([16]byte{1}, "test", universes)
+	if len(packets) != 3 {
+		t.Fatalf("Wrong page count! Was: %v; Should've been: %v", len(packets), 3)
+	}
+	lengths := []int{512, 512, 6}
+	for i, p := range packets {
+		if int(p.Page()) != i || p.LastPage() != 2 {
+			t.Errorf("Wrong page numbers! Was: %v/%v", p.Page(), p.LastPage())
+		}
+		if len(p.Universes()) != lengths[i] {
+			t.Errorf("Wrong universe count on page %v! Was: %v", i, len(p.Universes()))
+		}
+	}
+	if packets[0].Universes()[0] != 1 || packets[2].Universes()[5] != 1030 {
+		t.Error("Universes were not sorted!")
+	}
+	//exactly one full page
+	packets = newDiscoveryPackets([16]byte{1}, "test", universes[:512])
+	if len(packets) != 1 || packets[0].LastPage() != 0 {
+		t.Errorf("Wrong page count for 512 universes! Was: %v", len(packets))
+	}
+	//no universes still results in one empty page
+	packets = newDiscoveryPackets([16]byte{1}, "test", nil)
+	if len(packets) != 1 || len(packets[0].Universes()) != 0 {
+		t.Errorf("Wrong page count for no universes! Was: %v", len(packets))
+	}
+}
+
+func TestNewDiscoveryPacketRaw(t *testing.T) {
+	p := NewDiscoveryPacket()
+	p.SetSourceName("raw")
+	p.SetUniverses([]uint16{1, 7})
+	parsed, err := NewDiscoveryPacketRaw(p.getBytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsed.SourceName() != "raw" {
+		t.Errorf("Wrong source name! Was: %v", parsed.SourceName())
+	}
+	if u := parsed.Universes(); len(u) != 2 || u[0] != 1 || u[1] != 7 {
+		t.Errorf("Wrong universes! Was: %v", u)
+	}
+	if _, err := NewDiscoveryPacketRaw(p.getBytes()[:110]); err == nil {
+		t.Error("Err was nil! Packet was too short")
+	}
+	if _, err := NewDiscoveryPacketRaw(p.getBytes()[:122]); err == nil {
+		t.Error("Err was nil! The FAL is longer than the packet")
+	}
+	//additional bytes after the packet do not match the FAL
+	raw := append(append([]byte(nil), p.getBytes()...), 0xFF, 0xFF)
+	if _, err := NewDiscoveryPacketRaw(raw); !errors.Is(err, ErrInvalidFlagsLength) {
+		t.Errorf("Wrong error for additional bytes! Was: %v", err)
+	}
+	sync := NewSyncPacket()
+	if _, err := NewDiscoveryPacketRaw(append(sync.getBytes(), make([]byte, 100)...)); err == nil {
+		t.Error("Err was nil! A SyncPacket is not a discovery packet")
+	}
+}
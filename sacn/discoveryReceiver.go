@@ -0,0 +1,212 @@
+package sacn
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// a source is removed from the inventory, if no discovery packet was received for this duration
+const discoveryTimeout = discoveryInterval*2 + time.Second*5
+
+// DiscoveredSource holds the information about a source that announces its universes via
+// universe discovery packets.
+type DiscoveredSource struct {
+	CID        [16]byte
+	SourceName string
+	Universes  []uint16 //sorted list of all universes the source is transmitting on
+	LastSeen   time.Time
+}
+
+// DiscoveryReceiver listens for universe discovery packets and builds an inventory of all sources
+// and the universes they are transmitting on. It only joins the multicast groups of the discovery
+// universe, so no data packets have to be received.
+// A DiscoveryReceiver that was created with NewDiscoveryReceiver uses its own socket on port 5568,
+// so it can not be bound to the same address as a ReceiverSocket in the same process. To receive
+// data and discovery packets in one process, use ReceiverSocket.JoinDiscovery instead.
+type DiscoveryReceiver struct {
+	//receiver owns the socket, if the DiscoveryReceiver was created with NewDiscoveryReceiver.
+	//nil, if the packets are handed over by a ReceiverSocket that is used for data as well.
+	receiver *ReceiverSocket
+	mutex    sync.Mutex
+	sources  map[[16]byte]*discoverySource
+	//onSourceAdded gets called if a new source was discovered. Gets called in own goroutine
+	onSourceAdded func(source DiscoveredSource)
+	//onSourceRemoved gets called if a source has not sent discovery packets for too long.
+	//Gets called in own goroutine
+	onSourceRemoved func(source DiscoveredSource)
+}
+
+type discoverySource struct {
+	source   DiscoveredSource
+	complete bool              //true, if all pages were received at least once
+	pages    map[byte][]uint16 //the pages that were received for the current universe list
+	lastPage byte
+}
+
+/*
+NewDiscoveryReceiver creates a new receiver for universe discovery packets with its own socket.
+bind can be something like "192.168.1.2", "fe80::1%eth0" or "". The port 5568 is used, if bind does
+not contain a port. Like the ReceiverSocket, it listens on IPv4 and IPv6 without a bind address.
+The net.Interface is used to join the multicast groups of the discovery universe. On some OS
+(eg Windows) you have to provide an interface for multicast to work. On others "nil" may be enough.
+*/
+func NewDiscoveryReceiver(bind string, ifi *net.Interface) (*DiscoveryReceiver, error) {
+	r, err := NewReceiverSocket(bind, ifi)
+	if err != nil {
+		return nil, err
+	}
+	d, err := r.JoinDiscovery()
+	if err != nil {
+		_ = r.Close() //the join error is the one that matters
+		return nil, err
+	}
+	d.receiver = r
+	return d, nil
+}
+
+// newDiscoveryReceiver creates a DiscoveryReceiver without a socket
+func newDiscoveryReceiver() *DiscoveryReceiver {
+	return &DiscoveryReceiver{sources: make(map[[16]byte]*discoverySource)}
+}
+
+// Start starts a separate goroutine for handling incoming discovery packets.
+// If the goroutine is already running, nothing happens. If Close() was called previously, the
+// socket is opened again. If the DiscoveryReceiver belongs to a ReceiverSocket, nothing happens.
+func (d *DiscoveryReceiver) Start() error {
+	if d.receiver == nil {
+		return nil
+	}
+	return d.receiver.Start()
+}
+
+// Close stops the running goroutine and closes the udp socket. It returns after the goroutine has
+// stopped. Calling Close multiple times is safe. If the DiscoveryReceiver belongs to a
+// ReceiverSocket, nothing happens, use the Close of the ReceiverSocket instead.
+func (d *DiscoveryReceiver) Close() error {
+	if d.receiver == nil {
+		return nil
+	}
+	return d.receiver.Close()
+}
+
+// SetOnSourceAddedCallback sets the callback that gets called, when a source was discovered and its
+// complete universe list was received.
+func (d *DiscoveryReceiver) SetOnSourceAddedCallback(callback func(source DiscoveredSource)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.onSourceAdded = callback
+}
+
+// SetOnSourceRemovedCallback sets the callback that gets called, when a source has not sent any
+// discovery packets for too long.
+func (d *DiscoveryReceiver) SetOnSourceRemovedCallback(callback func(source DiscoveredSource)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.onSourceRemoved = callback
+}
+
+// Sources returns all sources that are currently known, sorted by their CID.
+func (d *DiscoveryReceiver) Sources() []DiscoveredSource {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	list := make([]DiscoveredSource, 0, len(d.sources))
+	for _, s := range d.sources {
+		if s.complete {
+			list = append(list, s.source.copy())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return bytes.Compare(list[i].CID[:], list[j].CID[:]) < 0
+	})
+	return list
+}
+
+// Source returns the source with the given CID. If the source is unknown, false is returned.
+func (d *DiscoveryReceiver) Source(cid [16]byte) (DiscoveredSource, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	s, ok := d.sources[cid]
+	if !ok || !s.complete {
+		return DiscoveredSource{}, false
+	}
+	return s.source.copy(), true
+}
+
+// SourcesForUniverse returns all sources that are transmitting on the given universe.
+func (d *DiscoveryReceiver) SourcesForUniverse(universe uint16) []DiscoveredSource {
+	list := make([]DiscoveredSource, 0)
+	for _, s := range d.Sources() {
+		i := sort.Search(len(s.Universes), func(i int) bool { return s.Universes[i] >= universe })
+		if i < len(s.Universes) && s.Universes[i] == universe {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// handle reassembles the pages of the discovery packets per source
+func (d *DiscoveryReceiver) handle(p DiscoveryPacket) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	s, ok := d.sources[p.CID()]
+	if !ok {
+		s = &discoverySource{
+			source: DiscoveredSource{CID: p.CID()},
+		}
+		d.sources[p.CID()] = s
+	}
+	s.source.SourceName = p.SourceName()
+	s.source.LastSeen = time.Now()
+	//a different page count means, that the universe list has changed and we start over
+	if s.pages == nil || s.lastPage != p.LastPage() {
+		s.pages = make(map[byte][]uint16)
+		s.lastPage = p.LastPage()
+	}
+	if p.Page() > p.LastPage() {
+		return
+	}
+	s.pages[p.Page()] = p.Universes()
+	if len(s.pages) != int(s.lastPage)+1 {
+		return
+	}
+	//all pages were received, so the universe list is complete
+	universes := make([]uint16, 0)
+	for page := 0; page <= int(s.lastPage); page++ {
+		universes = append(universes, s.pages[byte(page)]...)
+	}
+	sort.Slice(universes, func(i, j int) bool { return universes[i] < universes[j] })
+	s.source.Universes = universes
+	s.pages = nil
+	if !s.complete {
+		s.complete = true
+		if d.onSourceAdded != nil {
+			go d.onSourceAdded(s.source.copy())
+		}
+	}
+}
+
+// checkForTimeouts removes all sources that have not sent discovery packets for too long
+func (d *DiscoveryReceiver) checkForTimeouts() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for cid, s := range d.sources {
+		if time.Since(s.source.LastSeen) <= discoveryTimeout {
+			continue
+		}
+		delete(d.sources, cid)
+		if s.complete && d.onSourceRemoved != nil {
+			go d.onSourceRemoved(s.source.copy())
+		}
+	}
+}
+
+// copy returns a deep copy of the source
+func (s DiscoveredSource) copy() DiscoveredSource {
+	universes := make([]uint16, len(s.Universes))
+	copy(universes, s.Universes)
+	s.Universes = universes
+	return s
+}
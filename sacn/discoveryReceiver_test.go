@@ -0,0 +1,154 @@
+package sacn
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryReceiverHandle(t *testing.T) {
+	d := newDiscoveryReceiver()
+	added := make(chan DiscoveredSource, 1)
+	removed := make(chan DiscoveredSource, 1)
+	d.SetOnSourceAddedCallback(func(s DiscoveredSource) { added <- s })
+	d.SetOnSourceRemovedCallback(func(s DiscoveredSource) { removed <- s })
+
+	universes := make([]uint16, 600)
+	for i := range universes {
+		universes[i] = uint16(i + 1)
+	}
+	cid := [16]byte{1, 2, 3}
+	packets := newDiscoveryPackets(cid, "console", universes)
+	//the source is not known until all pages were received
+	d.handle(packets[1])
+	if len(d.Sources()) != 0 {
+		t.Error("Source should not be known with an incomplete universe list")
+	}
+	d.handle(packets[0])
+	select {
+	case s := <-added:
+		if s.CID != cid || s.SourceName != "console" || len(s.Universes) != 600 {
+			t.Errorf("Wrong source was added! Was: %v %v %v", s.CID, s.SourceName, len(s.Universes))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Added callback was not called")
+	}
+	if len(d.SourcesForUniverse(512)) != 1 || len(d.SourcesForUniverse(601)) != 0 {
+		t.Error("Wrong sources for universe")
+	}
+
+	//a changed universe list replaces the old one
+	packets = newDiscoveryPackets(cid, "console", []uint16{5})
+	d.handle(packets[0])
+	if s, ok := d.Source(cid); !ok || len(s.Universes) != 1 || s.Universes[0] != 5 {
+		t.Errorf("Universe list was not updated! Was: %v", s.Universes)
+	}
+
+	//let the source time out
+	d.sources[cid].source.LastSeen = time.Now().Add(-discoveryTimeout - time.Second)
+	d.checkForTimeouts()
+	select {
+	case s := <-removed:
+		if s.CID != cid {
+			t.Errorf("Wrong source was removed! Was: %v", s.CID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Removed callback was not called")
+	}
+	if len(d.Sources()) != 0 {
+		t.Error("Source was not removed from the inventory")
+	}
+}
+
+func TestDiscoveryReceiverClose(t *testing.T) {
+	d, err := NewDiscoveryReceiver("127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//closing without starting and closing twice must not panic
+	if err := d.Close(); err != nil {
+		t.Error(err)
+	}
+	d.Close()
+
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := d.Close(); err != nil {
+		t.Error(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Close took too long: %v", time.Since(start))
+	}
+	//the socket has to be released, so that a new receiver can be bound to the same address
+	d, err = NewDiscoveryReceiver("127.0.0.1", nil)
+	if err != nil {
+		t.Fatalf("Socket was not released: %v", err)
+	}
+	d.Close()
+}
+
+func TestReceiverJoinDiscovery(t *testing.T) {
+	r, err := NewReceiverSocket("127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	d, err := r.JoinDiscovery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := r.JoinDiscovery(); again != d {
+		t.Error("JoinDiscovery should return the same DiscoveryReceiver")
+	}
+	added := make(chan DiscoveredSource, 1)
+	d.SetOnSourceAddedCallback(func(s DiscoveredSource) { added <- s })
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) { ch <- new })
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("udp", "127.0.0.1:5568")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	//data and discovery packets are received on the same socket
+	p := newTestPacket(1, 0, []byte{1, 0})
+	conn.Write(p.getBytes())
+	expectData(t, ch, []byte{1, 0})
+	discovery := newDiscoveryPackets([16]byte{1}, "console", []uint16{1, 2})
+	conn.Write(discovery[0].getBytes())
+	select {
+	case s := <-added:
+		if s.CID != [16]byte{1} || len(s.Universes) != 2 {
+			t.Errorf("Wrong source was added! Was: %v %v", s.CID, s.Universes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Discovery packet was not handled")
+	}
+
+	//a full page of universes is larger than a data packet
+	universes := make([]uint16, discoveryPageSize)
+	for i := range universes {
+		universes[i] = uint16(i + 1)
+	}
+	discovery = newDiscoveryPackets([16]byte{2}, "console", universes)
+	conn.Write(discovery[0].getBytes())
+	select {
+	case s := <-added:
+		if s.CID != [16]byte{2} || len(s.Universes) != discoveryPageSize {
+			t.Errorf("Wrong source was added! Was: %v %v", s.CID, len(s.Universes))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Discovery packet with a full page was not handled")
+	}
+	//data packets that are larger than 638 bytes are still invalid
+	conn.Write(append(p.getBytes(), make([]byte, 600)...))
+	time.Sleep(50 * time.Millisecond)
+	if stats := r.Stats(); len(stats.Malformed) != 1 || stats.Malformed[ErrInvalidLength] != 1 {
+		t.Errorf("Only the oversized data packet should be malformed! Was: %v", stats.Malformed)
+	}
+}
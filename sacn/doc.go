@@ -8,37 +8,124 @@ http://opendmx.net/index.php/Open_Lighting_Architecture project.
 # Receiving
 
 The simplest way to receive sACN packets is to use `sacn.NewReceiverSocket`.
-
-The receiver checks for out-of-order packets (inspecting the sequence number) and sorts for priority.
-Synchronization must be implemented in the callers program, but currently there is no way to receive
-the sACN sync-packets. This feature may come in a future version.
+The change callback gets the old and the new data of a universe. If there was no data on the
+universe before or the universe had a timeout, the old packet is nil.
+
+The receiver tracks every source of a universe by its CID and checks for out-of-order packets
+(inspecting the sequence number) per source. The data of all sources on a universe is merged,
+by default only the sources with the highest priority are used and their data is merged per slot
+with "highest takes precedence". Other modes can be set with `receiver.SetMergeMode(<mode>)`.
+With `receiver.SetOnSourceOnlineCallback` and `receiver.SetOnSourceLostCallback` you get notified,
+if a single source starts or stops transmitting on a universe. The timeout callback is only called,
+if the last source of a universe is lost. A source that sets the stream_terminated flag is removed
+immediately and the remaining sources take over without waiting for the 2.5s timeout.
+Packets with the preview_data flag are passed through by default. With
+`receiver.SetPreviewMode(<mode>)` they can be dropped or handed to a separate preview callback,
+so visualisers and live fixtures can share one receiver.
+Per-address priorities (start code 0xDD) are used to decide the priority per slot. Packets with
+other alternate start codes are not handed out as DMX data.
+Packets with a non-zero sync address are held back until the matching sync packet arrives, so that
+multiple universes change at the same time. If no sync packets are received on that address,
+the data is processed immediately. When using multicast, the sync universe has to be joined as well.
+All packets are validated completely before they are used. Datagrams that are not valid E1.31
+packets, like Art-Net or RDMnet packets on the same port, are dropped. `sacn.NewDataPacketRaw`
+returns a `*sacn.PacketError` that wraps one of the `sacn.ErrInvalid...` errors for such data.
+To parse packets of any type, use `sacn.Decode`. It returns a `sacn.Packet`, which is either a
+`*sacn.DataPacket`, a `*sacn.SyncPacket` or a `*sacn.DiscoveryPacket`.
+`receiver.Stats()` counts the received packets per universe and source, and how many of them were
+not used: out of order, superseded by a higher priority, preview, terminated or with an unknown
+start code. These reasons are also available as errors like `sacn.ErrOutOfOrder`. Datagrams that
+could not be parsed are counted by their `sacn.ErrInvalid...` error.
 
 This `sacn.ReceiverSocket` can use multicast groups to receive its data. Unicast packets that are received
 are also processed like the normal unicast receiver. Depending on your operating system, you might can
 provide `nil` as an interface, sometimes you have to use a dedicated interface, to get multicast working.
 Windows needs an interface and Linux generally not.
+Without a bind address the receiver listens on IPv4 and IPv6 and `receiver.JoinUniverse` joins the
+groups 239.255.hi.lo and FF18::83:00:hi:lo. With an IPv4 or IPv6 bind address only this IP version
+is used.
 
 Note that the network infrastructure has to be multicast ready and that on some networks the delay of
 packets will increase. Also the packet loss can be higher if multicast is chosen
 (This is often a problem when WLAN is used). This can cause unintentional timeouts, if the sources
 are only transmitting every 2 seconds (like grandMA2 consoles).
 
+The receiver can be started in its own goroutine via `receiver.Start()` and stopped via
+`receiver.Close()`. Alternatively `receiver.Run(ctx)` blocks until the context is canceled and
+returns any error that occurs on the socket. Both stop immediately and can be restarted.
+On Linux the packets are read in batches with one recvmmsg call, on other systems one by one. They
+are parsed without copying.
+
+All callbacks are called in their own goroutine, so they may arrive out of order. If you need the
+events in order, use `receiver.Events(<size>, <policy>)`. It returns a channel that delivers data
+changes, timeouts, source and sync events in order. The policy decides what happens if the channel
+is full: block the receiver, drop the oldest event or coalesce data changes per universe.
+
+All methods of the `ReceiverSocket` are safe for concurrent use. `receiver.Snapshot(<universe>)`
+returns a copy of the current state of a universe: the merged data, all sources and the source whose
+header is used for the output. `receiver.Universes()` lists all universes that have sources.
+
+To see which sources are transmitting on which universes, use `receiver.JoinDiscovery()`. It joins
+the multicast groups of the discovery universe and returns a `*sacn.DiscoveryReceiver`, that builds
+an inventory of all sources from their universe discovery packets. Sources that stop announcing are
+removed after 25 seconds. If no data is needed, `sacn.NewDiscoveryReceiver` creates a receiver with
+its own socket on port 5568, so it can not be used next to a `ReceiverSocket` in the same process.
+
 # Transmitting
 
 To transmit DMX data, you have to initialize a `Transmitter` object. This handles all the protocol
 specific actions (currently not all). You can activate universes, if you wish to send out data.
 Then you can use a channel for 512-byte arrays to transmit them over the network.
+All methods of the `Transmitter` are safe for concurrent use.
+Data can only be sent on the universes 1 to 63999, 64214 is reserved for universe discovery.
+`transmitter.Activate`, all per-universe setters of the `Transmitter` and `receiver.JoinUniverse`
+return a `*sacn.UniverseError` for other universes.
+All universes are sent out from one udp socket. On Linux the packets of all universes are written in
+batches with one sendmmsg call, on other systems they are written one by one.
+
+To stop sending on a universe, close its channel or call `transmitter.Deactivate(<universe>)`.
+Receivers are notified with three packets that have the stream_terminated flag set. Call
+`transmitter.Close()` to terminate all universes and release the socket. Both `Deactivate` and
+`Close` return after all goroutines of the universes have stopped.
+
+The priority of all universes can be set via `transmitter.SetPriority(<prio>)`. Every universe can
+have its own priority, source name, preview flag and start code, for example via
+`transmitter.SetUniversePriority(<universe>, <prio>)`. All of them can be changed while the universe
+is activated and are used for the next packet.
+
+Errors while sending do not stop the transmitter. They are reported to the callback that is set
+via `transmitter.SetOnErrorCallback` and counted per destination, see
+`transmitter.DestinationStats()`. With `transmitter.SetBackoff(<duration>)` unicast destinations
+that are not reachable are skipped for the given duration.
+
+Every packet that is written to the channel is sent out immediately. To limit the packets per
+second of a universe, use `transmitter.SetMaxRefreshRate(<universe>, <rate>)`. Data that is written
+faster is coalesced and only the latest data is sent. After the data has changed, it is repeated
+three times before only the keep alive packets are sent (see `transmitter.SetKeepAlive`).
 
 There are two different types of addressing the receiver: unicast and multicast.
 When using multicast, note that you have to provide a bind address on some operating systems
 (eg Windows). You can use both at the same time and any number of unicast addresses.
 To set wether multicast should be used, call `transmitter.SetMulticast(<universe>, <bool>)`.
+IPv6 multicast is turned on with `transmitter.SetMulticastIPv6(<universe>, <bool>)`.
 You can set multiple unicast destinations as slice via
-`transmitter.SetDestinations(<universe>, <[]string>)`.
+`transmitter.SetDestinations(<universe>, <[]string>)`. Destinations can be IPv4 or IPv6 addresses
+with an optional port, like "192.168.1.2" or "[::1]:5568".
 Note that any existing destinations will be overwritten. If you want to append a destination, you
 can use `transmitter.Destination(<universe>)` which returns a deep copy of the used net.UDPAddr
 objects.
 
+If multiple universes should change at the same time, assign them to a sync universe via
+`transmitter.SetSyncUniverse(<sync>, <universes...>)` and send the data with
+`transmitter.SendSync(<sync>, <map[uint16][]byte>)`. This sends all universes and afterwards one
+sync packet to the multicast and unicast destinations of the sync universe.
+
+Per-address priorities can be sent alongside the DMX data of a universe with
+`transmitter.SetPerAddressPriority(<universe>, <[]byte>)`.
+
+While at least one universe is activated, the transmitter announces all activated universes every
+10 seconds with universe discovery packets on the multicast group of the `DiscoveryUniverse` 64214.
+
 Example
 
 	package main
@@ -57,6 +144,8 @@ Example
 		if err != nil {
 			log.Fatal(err)
 		}
+		//close the socket and terminate all universes on exit
+		defer trans.Close()
 
 		//activates the first universe
 		ch, err := trans.Activate(1)
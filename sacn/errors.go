@@ -0,0 +1,83 @@
+package sacn
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors that describe why raw bytes are not a valid E1.31 packet. The errors that are returned by
+// NewDataPacketRaw, NewSyncPacketRaw and NewDiscoveryPacketRaw are of the type *PacketError and
+// wrap one of these errors, so they can be checked with errors.Is.
+var (
+	// ErrInvalidLength is used, if the packet is too short or too long
+	ErrInvalidLength = errors.New("invalid length")
+	// ErrInvalidPreamble is used, if the preamble or postamble of the root layer is wrong
+	ErrInvalidPreamble = errors.New("invalid preamble or postamble")
+	// ErrInvalidIdentifier is used, if the packet does not contain the "ASC-E1.17" identifier
+	ErrInvalidIdentifier = errors.New("invalid ACN packet identifier")
+	// ErrInvalidVector is used, if a vector of one of the layers does not match the packet type
+	ErrInvalidVector = errors.New("invalid vector")
+	// ErrInvalidFlagsLength is used, if a flags and length field does not match the packet size
+	ErrInvalidFlagsLength = errors.New("invalid flags and length")
+	// ErrInvalidDMP is used, if the address type, first address or address increment of the DMP
+	// layer is wrong
+	ErrInvalidDMP = errors.New("invalid DMP layer")
+	// ErrInvalidUniverse is used, if the universe of a data packet is not in range [1-63999]. It is
+	// also wrapped by UniverseError.
+	ErrInvalidUniverse = errors.New("invalid universe")
+)
+
+// Errors that describe why a received packet was not used for the output of its universe. They are
+// counted per source, see ReceiverSocket.Stats.
+var (
+	// ErrOutOfOrder is used, if the sequence number of a packet is older than the last one of its source
+	ErrOutOfOrder = errors.New("out-of-order packet")
+	// ErrPreview is used, if a packet with the preview_data flag was dropped or handed to the
+	// preview callback, see SetPreviewMode
+	ErrPreview = errors.New("preview packet")
+	// ErrTerminated is used, if a packet has the stream_terminated flag set
+	ErrTerminated = errors.New("stream terminated")
+	// ErrUnknownStartCode is used, if a packet has an alternate start code that is not supported
+	ErrUnknownStartCode = errors.New("unknown start code")
+	// ErrSuperseded is used, if the data of a packet is not used, because another source on the
+	// universe has a higher priority
+	ErrSuperseded = errors.New("superseded by a source with a higher priority")
+)
+
+// UniverseError is returned, if a universe can not be used for data, because it is not in range
+// [MinUniverse-MaxUniverse]. It wraps ErrInvalidUniverse.
+type UniverseError struct {
+	Universe uint16
+}
+
+func (e *UniverseError) Error() string {
+	if e.Universe == DiscoveryUniverse {
+		return fmt.Sprintf("%v: %v is reserved for universe discovery", ErrInvalidUniverse, e.Universe)
+	}
+	return fmt.Sprintf("%v: %v is not in range [%v-%v]", ErrInvalidUniverse, e.Universe, MinUniverse, MaxUniverse)
+}
+
+// Unwrap returns ErrInvalidUniverse
+func (e *UniverseError) Unwrap() error {
+	return ErrInvalidUniverse
+}
+
+// PacketError is returned, if raw bytes could not be parsed as a packet. Err is one of the
+// ErrInvalid errors and Detail describes the problem.
+type PacketError struct {
+	Err    error
+	Detail string
+}
+
+func (e *PacketError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Err, e.Detail)
+}
+
+// Unwrap returns the error that describes the reason
+func (e *PacketError) Unwrap() error {
+	return e.Err
+}
+
+func newPacketError(err error, format string, a ...interface{}) *PacketError {
+	return &PacketError{Err: err, Detail: fmt.Sprintf(format, a...)}
+}
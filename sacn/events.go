@@ -0,0 +1,157 @@
+package sacn
+
+import "sync"
+
+// EventType is the type of an Event of the ReceiverSocket
+type EventType int
+
+const (
+	// EventDataChange is emitted if the merged data of a universe has changed. New is set and Old is
+	// set, if there was data on the universe before.
+	EventDataChange EventType = iota
+	// EventTimeout is emitted if the last source of a universe is lost
+	EventTimeout
+	// EventSourceOnline is emitted if a new source starts transmitting on a universe. Source is set.
+	EventSourceOnline
+	// EventSourceLost is emitted if a source on a universe had a timeout or terminated its stream.
+	// Source is set.
+	EventSourceLost
+	// EventSync is emitted if a sync packet was received. Universe is the sync address.
+	EventSync
+	// EventPreview is emitted for every preview packet, if the PreviewSeparate mode is used.
+	// New is set.
+	EventPreview
+)
+
+// OverflowPolicy determines what happens if the event channel is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the receiver until there is space for the event. No events are lost,
+	// but packets may be lost on the socket if the events are not read fast enough.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest event that was not read yet
+	OverflowDropOldest
+	// OverflowCoalesce combines a data change with the latest data change of the same universe that
+	// was not read yet, if the channel is full. The resulting event has the Old packet of the first
+	// and the New packet of the second event. A data change is never combined across a later event of
+	// another type on the same universe. If the events can not be combined, the oldest one is dropped.
+	OverflowCoalesce
+)
+
+// Event is a notification of the ReceiverSocket. Depending on the Type only some fields are set.
+type Event struct {
+	Type     EventType
+	Universe uint16
+	Old      *DataPacket //nil, if there was no data before or the universe had a timeout
+	New      DataPacket
+	Source   SourceInfo
+}
+
+// eventQueue is a bounded queue that delivers the events in order over a channel. The goroutine that
+// delivers the events holds one more event, so up to size+1 events are buffered.
+type eventQueue struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	events []Event
+	size   int
+	policy OverflowPolicy
+	out    chan Event
+	stop   chan struct{}
+	closed bool
+}
+
+func newEventQueue(size int, policy OverflowPolicy) *eventQueue {
+	if size < 1 {
+		size = 1
+	}
+	q := &eventQueue{
+		events: make([]Event, 0, size),
+		size:   size,
+		policy: policy,
+		out:    make(chan Event),
+		stop:   make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	go q.run()
+	return q
+}
+
+// push adds the event to the queue according to the overflow policy
+func (q *eventQueue) push(e Event) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for !q.closed && len(q.events) >= q.size {
+		switch q.policy {
+		case OverflowBlock:
+			q.cond.Wait()
+			continue
+		case OverflowCoalesce:
+			if q.coalesce(e) {
+				return
+			}
+		}
+		q.events = q.events[1:]
+	}
+	if q.closed {
+		return
+	}
+	q.events = append(q.events, e)
+	q.cond.Broadcast()
+}
+
+// coalesce combines the data change with the latest queued event of the same universe, if it is a
+// data change as well. Returns false, if the events could not be combined.
+func (q *eventQueue) coalesce(e Event) bool {
+	if e.Type != EventDataChange {
+		return false
+	}
+	for i := len(q.events) - 1; i >= 0; i-- {
+		if q.events[i].Universe != e.Universe {
+			continue
+		}
+		if q.events[i].Type != EventDataChange {
+			return false //the data change must not overtake other events of the universe
+		}
+		q.events[i].New = e.New
+		return true
+	}
+	return false
+}
+
+// run delivers the events of the queue to the channel until the queue is closed
+func (q *eventQueue) run() {
+	defer close(q.out)
+	for {
+		q.mutex.Lock()
+		for !q.closed && len(q.events) == 0 {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mutex.Unlock()
+			return
+		}
+		e := q.events[0]
+		q.events = q.events[1:]
+		q.cond.Broadcast()
+		q.mutex.Unlock()
+
+		select {
+		case q.out <- e:
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// close stops the delivery and closes the channel. Events that were not read are dropped.
+func (q *eventQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.stop)
+	q.cond.Broadcast()
+}
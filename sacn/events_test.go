@@ -0,0 +1,162 @@
+package sacn
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func dataEvent(universe uint16, old, new byte) Event {
+	oldPacket := newTestPacket(universe, 0, []byte{old})
+	return Event{
+		Type:     EventDataChange,
+		Universe: universe,
+		Old:      &oldPacket,
+		New:      newTestPacket(universe, 0, []byte{new}),
+	}
+}
+
+func readEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("No event was delivered")
+	}
+	return Event{}
+}
+
+func TestEventQueueDropOldest(t *testing.T) {
+	q := newEventQueue(2, OverflowDropOldest)
+	defer q.close()
+	//wait until the first event is taken by the delivering goroutine
+	q.push(dataEvent(1, 0, 1))
+	time.Sleep(10 * time.Millisecond)
+	for i := byte(2); i <= 5; i++ {
+		q.push(dataEvent(1, 0, i))
+	}
+	for _, want := range []byte{1, 4, 5} {
+		e := readEvent(t, q.out)
+		if e.New.Data()[0] != want {
+			t.Errorf("Wrong event! Was: %v; Should've been: %v", e.New.Data()[0], want)
+		}
+	}
+}
+
+func TestEventQueueCoalesce(t *testing.T) {
+	q := newEventQueue(3, OverflowCoalesce)
+	defer q.close()
+	q.push(Event{Type: EventSourceOnline, Universe: 1})
+	time.Sleep(10 * time.Millisecond)
+	//the data changes are only combined, if the queue is full
+	q.push(dataEvent(1, 0, 1))
+	q.push(dataEvent(2, 0, 7))
+	q.push(dataEvent(1, 1, 2))
+	q.push(dataEvent(1, 2, 3))
+
+	if e := readEvent(t, q.out); e.Type != EventSourceOnline {
+		t.Errorf("Wrong event type! Was: %v", e.Type)
+	}
+	tests := []struct {
+		universe uint16
+		old, new byte
+	}{{1, 0, 1}, {2, 0, 7}, {1, 1, 3}}
+	for _, want := range tests {
+		e := readEvent(t, q.out)
+		if e.Type != EventDataChange || e.Universe != want.universe ||
+			e.Old.Data()[0] != want.old || e.New.Data()[0] != want.new {
+			t.Errorf("Wrong event! Was: %v -> %v on %v; Should've been: %v", e.Old.Data()[0],
+				e.New.Data()[0], e.Universe, want)
+		}
+	}
+}
+
+func TestEventQueueCoalesceOrder(t *testing.T) {
+	q := newEventQueue(2, OverflowCoalesce)
+	defer q.close()
+	q.push(Event{Type: EventSourceOnline, Universe: 1})
+	time.Sleep(10 * time.Millisecond)
+	q.push(dataEvent(1, 0, 1))
+	q.push(Event{Type: EventTimeout, Universe: 1})
+	//the data change must not overtake the timeout, so the oldest event is dropped
+	q.push(dataEvent(1, 1, 2))
+
+	for _, want := range []EventType{EventSourceOnline, EventTimeout, EventDataChange} {
+		if e := readEvent(t, q.out); e.Type != want {
+			t.Errorf("Wrong event type! Was: %v; Should've been: %v", e.Type, want)
+		}
+	}
+}
+
+func TestEventQueueBlock(t *testing.T) {
+	q := newEventQueue(1, OverflowBlock)
+	q.push(dataEvent(1, 0, 1))
+	time.Sleep(10 * time.Millisecond)
+	q.push(dataEvent(1, 0, 2))
+	pushed := make(chan struct{})
+	go func() {
+		q.push(dataEvent(1, 0, 3))
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("Push did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	for _, want := range []byte{1, 2, 3} {
+		if e := readEvent(t, q.out); e.New.Data()[0] != want {
+			t.Errorf("Wrong event! Was: %v; Should've been: %v", e.New.Data()[0], want)
+		}
+	}
+	<-pushed
+	q.close()
+	if _, ok := <-q.out; ok {
+		t.Error("Channel was not closed")
+	}
+}
+
+func TestReceiverEventsOrderedConcurrent(t *testing.T) {
+	r := newTestReceiver()
+	r.SetMergeMode(MergeLTP)
+	ch := r.Events(10, OverflowBlock)
+	//like the listeners of the IPv4 and IPv6 sockets, the packets are handled concurrently
+	const workers, packets = 4, 200
+	for w := byte(1); w <= workers; w++ {
+		go func(w byte) {
+			for i := byte(1); i <= packets; i++ {
+				r.handleBatch(newTestBatch(newTestSourcePacket(w, 100, i, []byte{w, i})))
+			}
+		}(w)
+	}
+	//every data change has to start with the data of the previous one
+	var last []byte
+	for n := 0; n < workers*packets; {
+		e := readEvent(t, ch)
+		if e.Type != EventDataChange {
+			continue
+		}
+		n++
+		if last != nil && (e.Old == nil || !bytes.Equal(e.Old.Data(), last)) {
+			t.Fatalf("Events are not in order! Old was: %v; Previous new was: %v", e.Old, last)
+		}
+		last = append(last[:0], e.New.Data()...)
+	}
+}
+
+func TestReceiverEventsOrdered(t *testing.T) {
+	r := newTestReceiver()
+	ch := r.Events(100, OverflowBlock)
+	for i := byte(1); i <= 50; i++ {
+		r.handle(newTestSourcePacket(1, 100, i, []byte{i, 0}))
+	}
+	if e := readEvent(t, ch); e.Type != EventSourceOnline || e.Source.CID != [16]byte{1} {
+		t.Errorf("Wrong first event! Was: %v", e.Type)
+	}
+	for i := byte(1); i <= 50; i++ {
+		e := readEvent(t, ch)
+		if e.Type != EventDataChange || e.New.Data()[0] != i {
+			t.Fatalf("Events are not in order! Was: %v; Should've been: %v", e.New.Data()[0], i)
+		}
+	}
+}
@@ -4,8 +4,14 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"runtime"
+	"strings"
 )
 
+// batchIO is true, if the packets are read and written in batches with recvmmsg and sendmmsg.
+// x/net only batches on Linux and does not implement recvmsg and sendmsg at all on Windows.
+var batchIO = runtime.GOOS == "linux"
+
 // CalculateFal : Calculates the two bytes of a FlagsAndLength field of a sACN packet
 func calculateFal(length uint16) [2]byte {
 	return [2]byte{
@@ -29,6 +35,46 @@ func getAsUint32(arr []byte) uint32 {
 	return value
 }
 
+// checkRootLayer validates the root layer of the raw bytes of a packet: the preamble, the postamble,
+// the ACN packet identifier, the flags and length and the vector.
+func checkRootLayer(raw []byte, vector uint32) error {
+	if getAsUint32(raw[0:2]) != 0x0010 || getAsUint32(raw[2:4]) != 0 {
+		return newPacketError(ErrInvalidPreamble, "was %v", raw[0:4])
+	}
+	for i := 4; i < 16; i++ {
+		if raw[i] != constHeader[i] {
+			return newPacketError(ErrInvalidIdentifier, "was %q", raw[4:16])
+		}
+	}
+	if err := checkFlagsLength(raw, 16, "root"); err != nil {
+		return err
+	}
+	if v := getAsUint32(raw[18:22]); v != vector {
+		return newPacketError(ErrInvalidVector, "root vector was %v, expected %v", v, vector)
+	}
+	return nil
+}
+
+// checkFlagsLength validates the flags and length field at the given index. The length has to
+// cover the rest of the packet.
+func checkFlagsLength(raw []byte, index int, layer string) error {
+	flags := raw[index] >> 4
+	length := int(getAsUint32(raw[index:index+2]) & 0x0FFF)
+	if flags != 0x7 || length != len(raw)-index {
+		return newPacketError(ErrInvalidFlagsLength, "%v layer had flags %#x and length %v, expected %#x and %v",
+			layer, flags, length, 0x7, len(raw)-index)
+	}
+	return nil
+}
+
+// checkUniverse returns a *UniverseError, if the universe can not be used for data
+func checkUniverse(universe uint16) error {
+	if universe < MinUniverse || universe > MaxUniverse {
+		return &UniverseError{universe}
+	}
+	return nil
+}
+
 func calcMulticastAddr(universe uint16) string {
 	byt := getAsBytes16(universe)
 	return fmt.Sprintf("239.255.%v.%v", byt[0], byt[1])
@@ -39,6 +85,39 @@ func calcMulticastUDPAddr(universe uint16) *net.UDPAddr {
 	return addr
 }
 
+// calcMulticastAddr6 returns the IPv6 multicast address of the universe: FF18::83:00:hi:lo, where
+// the universe is the last 16-bit group of the address
+func calcMulticastAddr6(universe uint16) string {
+	return fmt.Sprintf("ff18::83:0:%04x", universe)
+}
+
+func calcMulticastUDPAddr6(universe uint16) *net.UDPAddr {
+	addr, _ := net.ResolveUDPAddr("udp", net.JoinHostPort(calcMulticastAddr6(universe), "5568"))
+	return addr
+}
+
+// joinHostPort adds the port to the given host, if it does not contain a port already. The host can
+// be an IPv4 address, an IPv6 address with or without brackets, a hostname or empty.
+func joinHostPort(host string, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), port)
+}
+
+// hostIP returns the IP address of the host part of the given address, nil if it is empty or a
+// hostname. The zone of an IPv6 address is ignored.
+func hostIP(address string) net.IP {
+	host, _, err := net.SplitHostPort(joinHostPort(address, "0"))
+	if err != nil {
+		return nil
+	}
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host)
+}
+
 func checkSequ(old, new byte) bool {
 	//calculate in int
 	tmp := int(new) - int(old)
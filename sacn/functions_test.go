@@ -2,6 +2,8 @@ package sacn
 
 import (
 	"bytes"
+	"errors"
+	"net"
 	"testing"
 )
 
@@ -36,6 +38,21 @@ func TestGetAsUint32(t *testing.T) {
 	}
 }
 
+func TestCheckUniverse(t *testing.T) {
+	for _, universe := range []uint16{1, 100, 63999} {
+		if err := checkUniverse(universe); err != nil {
+			t.Errorf("Universe %v should be valid! Was: %v", universe, err)
+		}
+	}
+	for _, universe := range []uint16{0, 64000, DiscoveryUniverse, 65535} {
+		err := checkUniverse(universe)
+		var uerr *UniverseError
+		if !errors.Is(err, ErrInvalidUniverse) || !errors.As(err, &uerr) || uerr.Universe != universe {
+			t.Errorf("Universe %v should be invalid! Was: %v", universe, err)
+		}
+	}
+}
+
 func TestCalcMulticastAddr(t *testing.T) {
 	out := calcMulticastAddr(257)
 	shouldBe := "239.255.1.1"
@@ -53,6 +70,39 @@ func TestCalcMulticastUdpAddr(t *testing.T) {
 	}
 }
 
+func TestCalcMulticastUDPAddr6(t *testing.T) {
+	out := calcMulticastUDPAddr6(0x1234)
+	shouldBe := net.ParseIP("ff18::83:0:1234")
+	if out.Port != 5568 || !out.IP.Equal(shouldBe) || !out.IP.IsMulticast() ||
+		out.IP[9] != 0 || out.IP[11] != 0x83 || out.IP[14] != 0x12 || out.IP[15] != 0x34 {
+		t.Errorf("IP should have been %v, was %v", shouldBe, out)
+	}
+}
+
+func TestJoinHostPort(t *testing.T) {
+	tests := []struct {
+		host, out string
+		ip        net.IP
+	}{
+		{"", ":5568", nil},
+		{"192.168.1.2", "192.168.1.2:5568", net.ParseIP("192.168.1.2")},
+		{"192.168.1.2:6000", "192.168.1.2:6000", net.ParseIP("192.168.1.2")},
+		{"::1", "[::1]:5568", net.IPv6loopback},
+		{"[::1]", "[::1]:5568", net.IPv6loopback},
+		{"[::1]:6000", "[::1]:6000", net.IPv6loopback},
+		{"fe80::1%eth0", "[fe80::1%eth0]:5568", net.ParseIP("fe80::1")},
+		{"localhost", "localhost:5568", nil},
+	}
+	for _, test := range tests {
+		if out := joinHostPort(test.host, "5568"); out != test.out {
+			t.Errorf("Wrong address for %q! Was: %v; Should've been: %v", test.host, out, test.out)
+		}
+		if ip := hostIP(test.host); !ip.Equal(test.ip) {
+			t.Errorf("Wrong IP for %q! Was: %v; Should've been: %v", test.host, ip, test.ip)
+		}
+	}
+}
+
 func TestCheckSequ(t *testing.T) {
 	if !checkSequ(12, 13) {
 		t.Error("Sequence was one higher, should be good!")
@@ -1,5 +1,5 @@
 module github.com/Hundemeier/go-sacn/sacn
 
-go 1.12
+go 1.13
 
 require golang.org/x/net v0.0.0-20221002022538-bcab6841153b
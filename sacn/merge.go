@@ -0,0 +1,124 @@
+package sacn
+
+// MergeMode determines how the data of multiple sources on the same universe is merged.
+type MergeMode int
+
+const (
+	// MergePriorityHTP only uses the sources with the highest priority and merges their data per
+	// slot with "highest takes precedence". This is the behaviour recommended by E1.31.
+	// If a source sends per-address priorities (start code 0xDD), the priority is decided per slot.
+	MergePriorityHTP MergeMode = iota
+	// MergeHTP merges the data of all sources per slot with "highest takes precedence".
+	// The priority of the sources is ignored.
+	MergeHTP
+	// MergeLTP uses the data of the source that has sent the latest packet ("latest takes
+	// precedence"). The priority of the sources is ignored.
+	MergeLTP
+)
+
+// merge calculates the output of the given sources with the given mode and stores it in out.
+// The buffer of out is reused. The header of the output is taken from the latest packet that was
+// used for merging. If there is no source with data, false is returned.
+func merge(mode MergeMode, sources map[[16]byte]*sourceData, out *DataPacket) bool {
+	var buf [8]*sourceData //most universes have only a few sources, so no allocation is needed
+	candidates := buf[:0]
+	maxPrio := byte(0)
+	perAddress := false
+	for _, s := range sources {
+		if !s.hasApplied {
+			continue
+		}
+		candidates = append(candidates, s)
+		if s.applied.Priority() > maxPrio {
+			maxPrio = s.applied.Priority()
+		}
+		perAddress = perAddress || s.priorities != nil
+	}
+	if mode == MergePriorityHTP && perAddress {
+		return mergePerAddress(candidates, out)
+	}
+	if mode == MergePriorityHTP {
+		filtered := candidates[:0]
+		for _, s := range candidates {
+			if s.applied.Priority() == maxPrio {
+				filtered = append(filtered, s)
+			}
+		}
+		candidates = filtered
+	}
+	if len(candidates) == 0 {
+		return false
+	}
+
+	var newest *sourceData
+	maxLength := 0
+	for _, s := range candidates {
+		if newest == nil || s.appliedTime.After(newest.appliedTime) {
+			newest = s
+		}
+		if len(s.applied.Data()) > maxLength {
+			maxLength = len(s.applied.Data())
+		}
+	}
+	out.set(newest.applied)
+	if mode == MergeLTP || len(candidates) == 1 {
+		return true
+	}
+
+	var data [512]byte
+	for _, s := range candidates {
+		for i, value := range s.applied.Data() {
+			if value > data[i] {
+				data[i] = value
+			}
+		}
+	}
+	out.SetData(data[:maxLength])
+	return true
+}
+
+// mergePerAddress merges the data of the given sources per slot. The slot priority of a source
+// is its per-address priority or the priority of the packet, if it does not send per-address
+// priorities. Only the sources with the highest slot priority are merged with HTP.
+func mergePerAddress(candidates []*sourceData, out *DataPacket) bool {
+	if len(candidates) == 0 {
+		return false
+	}
+	var newest *sourceData
+	maxLength := 0
+	for _, s := range candidates {
+		if newest == nil || s.appliedTime.After(newest.appliedTime) {
+			newest = s
+		}
+		if len(s.applied.Data()) > maxLength {
+			maxLength = len(s.applied.Data())
+		}
+	}
+	var data [512]byte
+	var slotPrio [512]int
+	for i := range slotPrio {
+		slotPrio[i] = -1 //no source controls the slot yet
+	}
+	for _, s := range candidates {
+		for i, value := range s.applied.Data() {
+			prio := int(s.applied.Priority())
+			if s.priorities != nil {
+				//a per-address priority of 0 means, that the source does not control the slot
+				if i >= len(s.priorities) || s.priorities[i] == 0 {
+					continue
+				}
+				prio = int(s.priorities[i])
+			}
+			if prio < slotPrio[i] {
+				continue
+			}
+			if prio > slotPrio[i] || value > data[i] {
+				data[i] = value
+			}
+			slotPrio[i] = prio
+		}
+	}
+	out.set(newest.applied)
+	out.SetData(data[:maxLength])
+	return true
+}
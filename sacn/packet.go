@@ -0,0 +1,56 @@
+package sacn
+
+// Packet is the common interface of all E1.31 packet types: *DataPacket, *SyncPacket and
+// *DiscoveryPacket. Use a type switch to access the fields of a specific packet type.
+type Packet interface {
+	// CID returns the unique identifier of the source
+	CID() [16]byte
+	// RootVector returns the vector of the root layer. It is 4 for data packets and 8 for
+	// extended packets like sync and discovery packets.
+	RootVector() uint32
+	// FramingVector returns the vector of the framing layer, which identifies the packet type
+	// together with the root vector
+	FramingVector() uint32
+	// Sequence returns the sequence number of the packet. Discovery packets do not have one,
+	// so it is always 0 for them.
+	Sequence() byte
+}
+
+// Decode parses the given raw bytes as an E1.31 packet. The type of the packet is determined by the
+// root and framing vectors and the result is either a *DataPacket, a *SyncPacket or a
+// *DiscoveryPacket. If the bytes are not a valid E1.31 packet, a *PacketError is returned.
+// The raw bytes are copied, so the buffer can be reused afterwards.
+func Decode(raw []byte) (Packet, error) {
+	if len(raw) < 44 {
+		return nil, newPacketError(ErrInvalidLength, "length has to be at least 44, was %v", len(raw))
+	}
+	switch v := getAsUint32(raw[18:22]); v {
+	case vectorRootE131Data:
+		p, err := NewDataPacketRaw(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &p, nil
+	case vectorRootE131Extended:
+	default:
+		return nil, newPacketError(ErrInvalidVector, "root vector was %v, expected %v or %v",
+			v, vectorRootE131Data, vectorRootE131Extended)
+	}
+	switch v := getAsUint32(raw[40:44]); v {
+	case vectorE131ExtendedSynchronization:
+		p, err := NewSyncPacketRaw(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &p, nil
+	case vectorE131ExtendedDiscovery:
+		p, err := NewDiscoveryPacketRaw(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &p, nil
+	default:
+		return nil, newPacketError(ErrInvalidVector, "framing vector was %v, expected %v or %v",
+			v, vectorE131ExtendedSynchronization, vectorE131ExtendedDiscovery)
+	}
+}
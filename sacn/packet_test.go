@@ -0,0 +1,75 @@
+package sacn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	cid := [16]byte{1, 2, 3}
+	data := NewDataPacket()
+	data.SetCID(cid)
+	data.SetUniverse(1)
+	data.SetSequence(7)
+	sync := NewSyncPacket()
+	sync.SetCID(cid)
+	sync.SetSequence(8)
+	discovery := newDiscoveryPackets(cid, "test", []uint16{1, 2})[0]
+
+	tests := []struct {
+		name     string
+		raw      []byte
+		sequence byte
+		check    func(p Packet) bool
+	}{
+		{"data", data.getBytes(), 7, func(p Packet) bool {
+			d, ok := p.(*DataPacket)
+			return ok && d.Universe() == 1
+		}},
+		{"sync", sync.getBytes(), 8, func(p Packet) bool {
+			_, ok := p.(*SyncPacket)
+			return ok
+		}},
+		{"discovery", discovery.getBytes(), 0, func(p Packet) bool {
+			d, ok := p.(*DiscoveryPacket)
+			return ok && len(d.Universes()) == 2
+		}},
+	}
+	for _, test := range tests {
+		p, err := Decode(test.raw)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !test.check(p) || p.CID() != cid || p.Sequence() != test.sequence {
+			t.Errorf("%v: wrong packet! Was: %#v", test.name, p)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	sync := NewSyncPacket()
+	unknownFraming := append([]byte{}, sync.getBytes()...)
+	unknownFraming[43] = 9
+	unknownRoot := append([]byte{}, sync.getBytes()...)
+	unknownRoot[21] = 9
+	invalidSync := append([]byte{}, sync.getBytes()...)
+	invalidSync[4] = 0
+
+	tests := []struct {
+		name string
+		raw  []byte
+		err  error
+	}{
+		{"too short", make([]byte, 20), ErrInvalidLength},
+		{"unknown root vector", unknownRoot, ErrInvalidVector},
+		{"unknown framing vector", unknownFraming, ErrInvalidVector},
+		{"invalid sync packet", invalidSync, ErrInvalidIdentifier},
+	}
+	for _, test := range tests {
+		p, err := Decode(test.raw)
+		if !errors.Is(err, test.err) || p != nil {
+			t.Errorf("%v: wrong result! Was: %v, %v; expected error: %v", test.name, p, err, test.err)
+		}
+	}
+}
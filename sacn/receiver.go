@@ -1,11 +1,16 @@
 package sacn
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net"
+	"sort"
+	"sync"
 	"time"
 
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // Set the timeout according to the E1.31 protocol
@@ -14,91 +19,541 @@ const timeoutMs = 2500
 // ReceiverSocket is used to listen on a network interface for sACN data.
 // The OnChangeCallback is used for changed DMX data. So if a source or priority changed,
 // this callback will not be invoked if not the DMX data has changed.
-// This Receiver checks for out-of-order packets and sorts out packets with too low priority.
+// This Receiver tracks every source of a universe by its CID and checks for out-of-order packets
+// per source. The data of all sources is merged according to the MergeMode, see SetMergeMode.
+// Packets with a sync address are held back until the corresponding sync packet arrives, as long as
+// sync packets are received on that address. Join the sync universe, if it is sent via multicast.
 type ReceiverSocket struct {
-	socket             *ipv4.PacketConn
-	stopListener       chan struct{}
-	multicastInterface *net.Interface // the interface that is used for joining multicast groups
+	//socketMutex guards the socket and all fields that are used for starting and stopping the listener
+	socketMutex        sync.Mutex
+	sockets            []receiverConn //the sockets for IPv4 and IPv6, nil if they were closed
+	bind               string
+	multicastInterface *net.Interface  // the interface that is used for joining multicast groups
+	joined             map[uint16]bool //the universes whose multicast-groups were joined
+	cancel             context.CancelFunc
+	done               chan struct{} //closed, if the running listener has stopped
+	//mutex guards the callbacks, the modes and the state of all universes
+	mutex sync.Mutex
 	//OnChangeCallback gets called if the data on one universe has changed. Gets called in own goroutine
-	onChangeCallback func(old DataPacket, new DataPacket)
+	onChangeCallback func(old *DataPacket, new DataPacket)
 	//TimeoutCallback gets called, if a timeout on a universe occurs. Gets called in own goroutine
 	timeoutCallback func(universe uint16)
-	lastDatas       map[uint16]lastData
-	timeoutCalled   map[uint16]bool //true, if the timeout was called. To prevent send a timeout callback twice
+	//onSourceOnline gets called if a new source on a universe appears. Gets called in own goroutine
+	onSourceOnline func(source SourceInfo)
+	//onSourceLost gets called if a source on a universe had a timeout. Gets called in own goroutine
+	onSourceLost func(source SourceInfo)
+	//onPreview gets called with every preview packet, if PreviewSeparate is used. Gets called in own goroutine
+	onPreview   func(p DataPacket)
+	previewMode PreviewMode
+	universes   map[uint16]*universeData
+	mergeMode   MergeMode
+	//pendingEvents are the events that are dispatched after the mutex is unlocked
+	pendingEvents []Event
+	//every call of unlockAndDispatch takes a ticket while the mutex is held and dispatches its events
+	//in the order of the tickets, because the sockets of both IP versions are read concurrently
+	dispatchNext  uint64 //the ticket for the next events, guarded by mutex
+	dispatchMutex sync.Mutex
+	dispatchCond  *sync.Cond //signals that the events of a ticket were dispatched, created on first use
+	dispatched    uint64     //the ticket whose events are dispatched next, guarded by dispatchMutex
+	eventsMutex   sync.Mutex
+	events        *eventQueue //nil, if the event channel is not used
+	//syncHeld stores the universes per source and sync address that have data waiting for a sync packet
+	syncHeld   map[syncKey]map[uint16]bool
+	lastSync   map[syncKey]lastSyncData //the last sync packet per source and sync address
+	lastExpire time.Time                //the last time the sources were checked for timeouts
+	//stats holds the packet statistics per universe and source, they are kept after a source is lost
+	stats     map[uint16]map[[16]byte]*PacketStats
+	malformed map[error]uint64   //the number of packets that could not be parsed per reason
+	discovery *DiscoveryReceiver //gets the discovery packets, nil if JoinDiscovery was not called
 }
 
-type lastData struct {
-	lastTime   time.Time
-	lastPacket DataPacket
+// PreviewMode determines how packets with the preview_data flag are handled by the receiver.
+// Preview data is meant for visualisers and should not be used for live output.
+type PreviewMode int
+
+const (
+	// PreviewPass handles preview packets like all other packets. This is the default.
+	PreviewPass PreviewMode = iota
+	// PreviewDrop ignores all preview packets
+	PreviewDrop
+	// PreviewSeparate hands preview packets only to the preview callback, see SetPreviewCallback.
+	// They are not merged with the live data.
+	PreviewSeparate
+)
+
+// universeData holds all sources of a universe and the merged output
+type universeData struct {
+	sources   map[[16]byte]*sourceData
+	output    DataPacket //the merged packet that was last handed out via the callback
+	merged    DataPacket //the buffer in which the data of the sources is merged
+	hasOutput bool
+	lastTime  time.Time //the time the last packet of any source was received
+}
+
+// sourceData holds the state of one source on a universe
+type sourceData struct {
+	lastTime    time.Time  //the time the last packet of this source was received
+	lastPacket  DataPacket //the last received packet, used for checking the sequence
+	applied     DataPacket //the packet that is used for merging
+	appliedTime time.Time
+	hasApplied  bool
+	pending     DataPacket //the packet that waits for a sync packet
+	hasPending  bool
+	priorities  []byte    //the per-address priorities of the source (start code 0xDD)
+	prioTime    time.Time //the time the last per-address priority packet was received
+}
+
+// SourceInfo describes a source that is transmitting on a universe. The values are taken from the
+// last packet that was received from this source.
+type SourceInfo struct {
+	Universe   uint16
+	CID        [16]byte
+	SourceName string
+	Priority   byte
+	Sequence   byte
+	LastSeen   time.Time
+}
+
+// UniverseSnapshot is a copy of the state of a universe at the time it was taken
+type UniverseSnapshot struct {
+	Universe     uint16
+	Data         DataPacket //the merged data that was last handed out, only valid if HasData is true
+	HasData      bool
+	LastSeen     time.Time    //the time the last packet of any source was received
+	Sources      []SourceInfo //all sources that are currently transmitting, sorted by their CID
+	ActiveSource *SourceInfo  //the source of the latest packet that was used for the data, nil if none
+}
+
+// PacketStats holds the number of data packets that were received and why they were not used.
+// Received counts all valid data packets, the other counters are a part of them.
+type PacketStats struct {
+	Received         uint64
+	OutOfOrder       uint64 //see ErrOutOfOrder
+	Superseded       uint64 //see ErrSuperseded
+	Preview          uint64 //see ErrPreview
+	Terminated       uint64 //see ErrTerminated
+	UnknownStartCode uint64 //see ErrUnknownStartCode
+}
+
+// SourceStats holds the packet statistics of one source on a universe
+type SourceStats struct {
+	Universe uint16
+	CID      [16]byte
+	PacketStats
+}
+
+// ReceiverStats is a copy of the statistics of a ReceiverSocket at the time it was taken
+type ReceiverStats struct {
+	//Malformed holds the number of packets that could not be parsed, by their reason. The keys are
+	//the sentinel errors like ErrInvalidLength.
+	Malformed map[error]uint64
+	Universes map[uint16]PacketStats //the sum of all sources per universe
+	Sources   []SourceStats          //sorted by universe and CID
+}
+
+// count increments the counters for a packet that was not used because of err, or that was used,
+// if err is nil
+func (s *PacketStats) count(err error) {
+	s.Received++
+	switch err {
+	case ErrOutOfOrder:
+		s.OutOfOrder++
+	case ErrSuperseded:
+		s.Superseded++
+	case ErrPreview:
+		s.Preview++
+	case ErrTerminated:
+		s.Terminated++
+	case ErrUnknownStartCode:
+		s.UnknownStartCode++
+	}
+}
+
+// add adds all counters of o to s
+func (s *PacketStats) add(o PacketStats) {
+	s.Received += o.Received
+	s.OutOfOrder += o.OutOfOrder
+	s.Superseded += o.Superseded
+	s.Preview += o.Preview
+	s.Terminated += o.Terminated
+	s.UnknownStartCode += o.UnknownStartCode
+}
+
+// syncKey identifies the sync packets of one source on one sync address. Every source synchronizes
+// only its own data, even if several sources share a sync address.
+type syncKey struct {
+	cid  [16]byte
+	sync uint16
+}
+
+type lastSyncData struct {
+	lastTime time.Time
+	sequence byte
 }
 
 /*
 NewReceiverSocket creates a new unicast Receiver socket that is capable of listening on the given
-interface (string is for binding). bind can be something like "192.168.1.2" (without a port!).
-This bind is only used for unicast receiving.
+interface (string is for binding). bind can be something like "192.168.1.2", "fe80::1%eth0" or "".
+The port 5568 is used, if bind does not contain a port. This bind is only used for unicast receiving.
+With an IPv4 or IPv6 address only this IP version is received, otherwise both are received with
+one socket each, if the host supports IPv6.
 The net.Interface is used to join multicast groups. On some OS (eg Windows) you have
 to provide an interface for multicast to work. On others "nil" may be enough. If you don't want
 to use multicast for receiving, just provide "nil".
 */
 func NewReceiverSocket(bind string, ifi *net.Interface) (*ReceiverSocket, error) {
-	r := &ReceiverSocket{}
-
-	ServerConn, err := net.ListenPacket("udp4", bind+":5568")
+	r := &ReceiverSocket{
+		bind:               bind,
+		multicastInterface: ifi,
+		joined:             make(map[uint16]bool),
+	}
+	err := r.open()
 	if err != nil {
 		return r, err
 	}
-	r.multicastInterface = ifi
-	r.socket = ipv4.NewPacketConn(ServerConn)
-	r.lastDatas = make(map[uint16]lastData)
-	r.timeoutCalled = make(map[uint16]bool)
+	r.universes = make(map[uint16]*universeData)
+	r.syncHeld = make(map[syncKey]map[uint16]bool)
+	r.lastSync = make(map[syncKey]lastSyncData)
+	r.stats = make(map[uint16]map[[16]byte]*PacketStats)
+	r.malformed = make(map[error]uint64)
 	return r, nil
 }
 
-// JoinUniverse joins the used udp socket to the multicast-group that is used for the universe.
-// After the multicast-group was joined, any source that transmit on this universe via multicast
-// should reach this socket.
+// open opens the udp sockets, if they are closed, and joins all multicast-groups that were joined
+// before. If bind is an IPv4 or IPv6 address, only a socket for this IP version is opened. Otherwise
+// a socket for IPv4 and one for IPv6 is opened, if the host supports IPv6.
+// The socketMutex has to be held by the caller, except on creation.
+func (r *ReceiverSocket) open() error {
+	if r.sockets != nil {
+		return nil
+	}
+	address := joinHostPort(r.bind, "5568")
+	ip := hostIP(r.bind)
+	useIPv4, useIPv6 := ip == nil || ip.To4() != nil, ip == nil || ip.To4() == nil
+	sockets := make([]receiverConn, 0, 2)
+	if useIPv4 {
+		conn, err := net.ListenPacket("udp4", address)
+		if err != nil {
+			return err
+		}
+		sockets = append(sockets, receiverConn{batchConn: ipv4.NewPacketConn(conn), conn: conn, batch: batchIO})
+	}
+	if useIPv6 {
+		conn, err := net.ListenPacket("udp6", address)
+		//without an IPv6 bind address, IPv6 and its multicast groups are optional, because not every
+		//host supports them
+		if err != nil && !useIPv4 {
+			return err
+		}
+		if err == nil {
+			sockets = append(sockets, receiverConn{batchConn: ipv6.NewPacketConn(conn), conn: conn,
+				batch: batchIO, ipv6: true, optional: useIPv4})
+		}
+	}
+	for universe := range r.joined {
+		err := joinGroups(sockets, r.multicastInterface, universe)
+		if err != nil {
+			_ = closeAll(sockets) //the join error is the one that matters
+			return err
+		}
+	}
+	r.sockets = sockets
+	return nil
+}
+
+// JoinUniverse joins the used udp sockets to the multicast-groups that are used for the universe:
+// 239.255.hi.lo for IPv4 and FF18::83:00:hi:lo for IPv6. Without a bind address, errors of the
+// IPv6 group are ignored, so IPv4 multicast works on hosts without IPv6 multicast.
+// After the multicast-groups were joined, any source that transmit on this universe via multicast
+// should reach this socket. If the receiver is closed, the group is joined when it is started again.
 // Please read the notice above about multicast use.
-func (r *ReceiverSocket) JoinUniverse(universe uint16) {
-	err := r.socket.JoinGroup(r.multicastInterface, calcMulticastUDPAddr(universe))
-	if err != nil {
-		panic(fmt.Sprintf("could not join multicast group for universe %v: %v", universe, err))
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (r *ReceiverSocket) JoinUniverse(universe uint16) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	r.socketMutex.Lock()
+	defer r.socketMutex.Unlock()
+	if err := joinGroups(r.sockets, r.multicastInterface, universe); err != nil {
+		return err
 	}
+	r.joined[universe] = true
+	return nil
 }
 
-// LeaveUniverse will leave the multicast-group of the given universe.
+// LeaveUniverse will leave the multicast-groups of the given universe.
 // If the the socket was not joined to the multicast-group nothing will happen.
 // Please note, that if you leave a group, a timeout may occur, because no more data has arrived.
-func (r *ReceiverSocket) LeaveUniverse(universe uint16) {
-	err := r.socket.LeaveGroup(r.multicastInterface, calcMulticastUDPAddr(universe))
-	if err != nil {
-		panic(fmt.Sprintf("could not leave multicast group for universe %v: %v", universe, err))
+func (r *ReceiverSocket) LeaveUniverse(universe uint16) error {
+	r.socketMutex.Lock()
+	defer r.socketMutex.Unlock()
+	if !r.joined[universe] {
+		return nil
+	}
+	for _, socket := range r.sockets {
+		group := socket.group(universe)
+		if err := socket.LeaveGroup(r.multicastInterface, group); err != nil && !socket.optional {
+			return fmt.Errorf("could not leave multicast group %v for universe %v: %v", group.IP, universe, err)
+		}
+	}
+	delete(r.joined, universe)
+	return nil
+}
+
+// JoinDiscovery joins the multicast groups of the discovery universe and returns an inventory of all
+// sources that announce their universes with universe discovery packets. The packets are received
+// with the sockets of this receiver, so data and discovery packets can be received in one process.
+// Calling JoinDiscovery again returns the same DiscoveryReceiver.
+func (r *ReceiverSocket) JoinDiscovery() (*DiscoveryReceiver, error) {
+	r.socketMutex.Lock()
+	defer r.socketMutex.Unlock()
+	if !r.joined[DiscoveryUniverse] {
+		if err := joinGroups(r.sockets, r.multicastInterface, DiscoveryUniverse); err != nil {
+			return nil, err
+		}
+		r.joined[DiscoveryUniverse] = true
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.discovery == nil {
+		r.discovery = newDiscoveryReceiver()
 	}
+	return r.discovery, nil
 }
 
-// Close will close the open udp socket and stops the running goroutine.
-// If you want to receive again, use Start(). Do not call close twice!
-func (r *ReceiverSocket) Close() {
-	close(r.stopListener) // stop the running listener on the socket, because we will close the socket
+/*
+Run handles incoming sACN traffic until the given context is canceled or Close is called.
+It blocks and returns immediately after the cancellation, the socket is not closed, so Run can
+be called again afterwards. If the receiver was closed before, the socket is opened again and
+all multicast-groups are joined again.
+
+Run returns the error of the context, if it was canceled, and nil, if Close was called.
+Any error of the socket is returned as well. Only one listener can run at the same time.
+*/
+func (r *ReceiverSocket) Run(ctx context.Context) error {
+	listenCtx, sockets, err := r.begin(ctx)
+	if err != nil {
+		return err
+	}
+	err = r.listen(listenCtx, sockets)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
 }
 
 // Start starts a separate goroutine for handling incoming sACN traffic.
-// If the goroutine is already running, nothing happens. If Close() was called previously,
-// keep in mind, that it takes up to 2.5 seconds to stop the existing goroutine.
-func (r *ReceiverSocket) Start() {
-	if r.stopListener == nil {
-		r.stopListener = make(chan struct{})
-		r.startListener()
+// If the goroutine is already running, nothing happens. If Close() was called previously, the
+// socket is opened again. Errors that occur while receiving stop the goroutine, use Run if you
+// want to handle them.
+func (r *ReceiverSocket) Start() error {
+	r.socketMutex.Lock()
+	running := r.cancel != nil
+	r.socketMutex.Unlock()
+	if running {
+		return nil
+	}
+	ctx, sockets, err := r.begin(context.Background())
+	if err != nil {
+		return err
+	}
+	go r.listen(ctx, sockets)
+	return nil
+}
+
+// begin prepares a new listener: it opens the socket and registers the cancel function, so that
+// Close can stop the listener.
+func (r *ReceiverSocket) begin(ctx context.Context) (context.Context, []receiverConn, error) {
+	r.socketMutex.Lock()
+	defer r.socketMutex.Unlock()
+	if r.cancel != nil {
+		return nil, nil, fmt.Errorf("the receiver is already running")
 	}
+	err := r.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	return ctx, r.sockets, nil
 }
 
-// SetOnChangeCallback sets the given function as callback for the receiver. If no old DataPacket can
-// be provided, it is a packet with universe 0.
-func (r *ReceiverSocket) SetOnChangeCallback(callback func(old DataPacket, new DataPacket)) {
+// Close stops the running listener and closes the udp socket. It returns after the listener has
+// stopped. Calling Close multiple times is safe. If you want to receive again, use Start() or Run().
+// The event channel is closed as well.
+func (r *ReceiverSocket) Close() error {
+	//the event queue is closed first, because the listener may be blocked by a full queue
+	r.eventsMutex.Lock()
+	if r.events != nil {
+		r.events.close()
+		r.events = nil
+	}
+	r.eventsMutex.Unlock()
+
+	r.socketMutex.Lock()
+	cancel, done := r.cancel, r.done
+	r.socketMutex.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	r.socketMutex.Lock()
+	defer r.socketMutex.Unlock()
+	if r.sockets == nil {
+		return nil
+	}
+	err := closeAll(r.sockets)
+	r.sockets = nil
+	return err
+}
+
+// SetOnChangeCallback sets the given function as callback for the receiver. If there was no data on
+// the universe before or the universe had a timeout, old is nil.
+func (r *ReceiverSocket) SetOnChangeCallback(callback func(old *DataPacket, new DataPacket)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	r.onChangeCallback = callback
 }
 
 // SetTimeoutCallback sets the callback for timeouts. The callback gets called every time a timeout is
 // recognized.
 func (r *ReceiverSocket) SetTimeoutCallback(callback func(universe uint16)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	r.timeoutCallback = callback
 }
+
+// SetMergeMode sets the mode that is used for merging the data of multiple sources on one universe.
+// The default is MergePriorityHTP.
+func (r *ReceiverSocket) SetMergeMode(mode MergeMode) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.mergeMode = mode
+}
+
+// SetOnSourceOnlineCallback sets the callback that gets called every time a new source starts
+// transmitting on a universe. Sources are identified by their CID.
+func (r *ReceiverSocket) SetOnSourceOnlineCallback(callback func(source SourceInfo)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onSourceOnline = callback
+}
+
+// SetOnSourceLostCallback sets the callback that gets called every time a source on a universe had
+// a timeout. The timeout callback for the universe is only called, if the last source is lost.
+func (r *ReceiverSocket) SetOnSourceLostCallback(callback func(source SourceInfo)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onSourceLost = callback
+}
+
+// SetPreviewMode sets how packets with the preview_data flag are handled. The default is PreviewPass.
+func (r *ReceiverSocket) SetPreviewMode(mode PreviewMode) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.previewMode = mode
+}
+
+// SetPreviewCallback sets the callback that gets called with every preview packet, if the
+// PreviewSeparate mode is used. Preview packets are not checked for sequence or priority.
+func (r *ReceiverSocket) SetPreviewCallback(callback func(p DataPacket)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onPreview = callback
+}
+
+/*
+Events returns a channel that delivers all events of the receiver in the order they occurred:
+data changes, timeouts, sources that are online or lost, sync packets and preview packets.
+This is an alternative to the callbacks, which are called in their own goroutines and therefore
+may arrive out of order. The callbacks are still called, if they are set.
+
+Up to size events are buffered, plus one event that is waiting to be read from the channel.
+If the buffer is full, the policy decides what happens.
+Calling Events again closes the previous channel. The channel is closed, if Close is called.
+*/
+func (r *ReceiverSocket) Events(size int, policy OverflowPolicy) <-chan Event {
+	r.eventsMutex.Lock()
+	defer r.eventsMutex.Unlock()
+	if r.events != nil {
+		r.events.close()
+	}
+	r.events = newEventQueue(size, policy)
+	return r.events.out
+}
+
+// Universes returns all universes on which data was received, sorted in ascending order.
+// It is safe to call this from other goroutines.
+func (r *ReceiverSocket) Universes() []uint16 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	list := make([]uint16, 0, len(r.universes))
+	for universe := range r.universes {
+		list = append(list, universe)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// Snapshot returns a copy of the current state of the given universe. If no source is transmitting on
+// the universe, false is returned. It is safe to call this from other goroutines.
+func (r *ReceiverSocket) Snapshot(universe uint16) (UniverseSnapshot, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	univ, ok := r.universes[universe]
+	if !ok {
+		return UniverseSnapshot{}, false
+	}
+	snapshot := UniverseSnapshot{
+		Universe: universe,
+		HasData:  univ.hasOutput,
+		LastSeen: univ.lastTime,
+		Sources:  make([]SourceInfo, 0, len(univ.sources)),
+	}
+	if univ.hasOutput {
+		snapshot.Data = univ.output.copy()
+	}
+	for cid, src := range univ.sources {
+		info := src.info()
+		snapshot.Sources = append(snapshot.Sources, info)
+		if univ.hasOutput && cid == univ.output.CID() {
+			snapshot.ActiveSource = &info
+		}
+	}
+	sort.Slice(snapshot.Sources, func(i, j int) bool {
+		return bytes.Compare(snapshot.Sources[i].CID[:], snapshot.Sources[j].CID[:]) < 0
+	})
+	return snapshot, true
+}
+
+// Stats returns the number of received packets per universe and source, and the number of packets
+// that could not be parsed. The statistics of a source are kept after it was lost.
+func (r *ReceiverSocket) Stats() ReceiverStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	stats := ReceiverStats{
+		Malformed: make(map[error]uint64, len(r.malformed)),
+		Universes: make(map[uint16]PacketStats, len(r.stats)),
+		Sources:   make([]SourceStats, 0),
+	}
+	for reason, n := range r.malformed {
+		stats.Malformed[reason] = n
+	}
+	for universe, sources := range r.stats {
+		var sum PacketStats
+		for cid, s := range sources {
+			sum.add(*s)
+			stats.Sources = append(stats.Sources, SourceStats{Universe: universe, CID: cid, PacketStats: *s})
+		}
+		stats.Universes[universe] = sum
+	}
+	sort.Slice(stats.Sources, func(i, j int) bool {
+		a, b := stats.Sources[i], stats.Sources[j]
+		if a.Universe != b.Universe {
+			return a.Universe < b.Universe
+		}
+		return bytes.Compare(a.CID[:], b.CID[:]) < 0
+	})
+	return stats
+}
@@ -2,117 +2,573 @@ package sacn
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"sync"
 	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// readBatchSize is the maximum number of packets that are read with one syscall
+	readBatchSize = 32
+	// expireInterval is the minimum time between two checks for timeouts while packets are received
+	expireInterval = time.Millisecond * 100
+	// readBufferSize is large enough for a discovery packet with a full page of universes. Data
+	// packets use only the first 638 bytes of the buffer.
+	readBufferSize = discoveryHeaderLength + 2*discoveryPageSize
 )
 
-// the listener is responsible for listening on the UDP socket and parsing the incoming data.
-// It dispatches the received packets to the corresponding handlers.
-func (r *ReceiverSocket) startListener() {
+// readBatchPool holds the buffers for reading packets in batches, so that they are not allocated
+// every time a listener starts
+var readBatchPool = sync.Pool{
+	New: func() interface{} {
+		msgs := make([]ipv4.Message, readBatchSize)
+		for i := range msgs {
+			msgs[i].Buffers = [][]byte{make([]byte, readBufferSize)}
+		}
+		return &msgs
+	},
+}
+
+// batchConn is the part of *ipv4.PacketConn and *ipv6.PacketConn that is used by the receiver
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	SetReadDeadline(t time.Time) error
+	JoinGroup(ifi *net.Interface, group net.Addr) error
+	LeaveGroup(ifi *net.Interface, group net.Addr) error
+	Close() error
+}
+
+// receiverConn is a socket of the receiver for either IPv4 or IPv6
+type receiverConn struct {
+	batchConn
+	conn     net.PacketConn //the underlying socket, that is read from if batch is false
+	batch    bool           //true, if the packets are read with ReadBatch, see batchIO
+	ipv6     bool
+	optional bool //true, if errors of the multicast groups are ignored, because no IP version was requested
+}
+
+// readBatch reads the packets that are available into msgs and returns their number. Without
+// batchIO only one packet is read with ReadFrom.
+func (c receiverConn) readBatch(msgs []ipv4.Message) (int, error) {
+	if c.batch {
+		//on Linux all packets that are available are read with one recvmmsg call
+		return c.ReadBatch(msgs, 0)
+	}
+	n, addr, err := c.conn.ReadFrom(msgs[0].Buffers[0])
+	if err != nil {
+		return 0, err
+	}
+	msgs[0].N, msgs[0].Addr = n, addr
+	return 1, nil
+}
+
+// group returns the multicast address of the universe for the IP version of the socket
+func (c receiverConn) group(universe uint16) *net.UDPAddr {
+	if c.ipv6 {
+		return calcMulticastUDPAddr6(universe)
+	}
+	return calcMulticastUDPAddr(universe)
+}
+
+// joinGroups joins the multicast groups of the universe on all sockets. If one group could not be
+// joined, the groups that were joined before are left again. Errors of optional sockets are ignored.
+func joinGroups(sockets []receiverConn, ifi *net.Interface, universe uint16) error {
+	for i, socket := range sockets {
+		group := socket.group(universe)
+		if err := socket.JoinGroup(ifi, group); err != nil && !socket.optional {
+			for _, joined := range sockets[:i] {
+				//the join error is returned, a failed leave is only a stale membership until Close
+				_ = joined.LeaveGroup(ifi, joined.group(universe))
+			}
+			return fmt.Errorf("could not join multicast group %v for universe %v: %v", group.IP, universe, err)
+		}
+	}
+	return nil
+}
+
+// closeAll closes all sockets and returns the first error
+func closeAll(sockets []receiverConn) error {
+	var err error
+	for _, socket := range sockets {
+		if e := socket.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// the listener is responsible for listening on the UDP sockets and parsing the incoming data.
+// Every socket is read in its own goroutine. If one of them fails, the others are stopped as well
+// and the error is returned. If the context is canceled, the deadline of the sockets is set to now,
+// so that the blocking reads return immediately.
+func (r *ReceiverSocket) listen(ctx context.Context, sockets []receiverConn) error {
+	defer func() {
+		r.socketMutex.Lock()
+		r.cancel()
+		close(r.done)
+		r.cancel = nil //set to nil, so it can be used as indicator if the listener is running
+		r.socketMutex.Unlock()
+	}()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopped := make(chan struct{})
+	defer close(stopped)
 	go func() {
-		buf := make([]byte, 638)
-	Loop:
-		for {
-			select {
-			case <-r.stopListener:
-				break Loop //break if we had a stop signal from the stopChannel
-			default:
+		select {
+		case <-ctx.Done():
+			r.socketMutex.Lock()
+			for _, socket := range sockets {
+				//an error means the socket is closed already, so the read returns anyway
+				_ = socket.SetReadDeadline(time.Now())
 			}
+			r.socketMutex.Unlock()
+		case <-stopped:
+		}
+	}()
 
-			err := r.socket.SetDeadline(time.Now().Add(time.Millisecond * timeoutMs))
-			if err != nil {
-				panic(fmt.Sprintf("could not set deadline on socket: %v", err))
+	errs := make(chan error, len(sockets))
+	for _, socket := range sockets {
+		go func(socket receiverConn) {
+			errs <- r.read(ctx, socket)
+		}(socket)
+	}
+	var err error
+	for range sockets {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			cancel()
+		}
+	}
+	return err
+}
+
+// read reads the packets of one socket in batches and handles them, until the context is canceled
+// or an error occurs
+func (r *ReceiverSocket) read(ctx context.Context, socket receiverConn) error {
+	batch := readBatchPool.Get().(*[]ipv4.Message)
+	defer readBatchPool.Put(batch)
+	for {
+		//the mutex prevents that the deadline of a cancellation gets overwritten
+		r.socketMutex.Lock()
+		if ctx.Err() != nil {
+			r.socketMutex.Unlock()
+			return nil
+		}
+		err := socket.SetReadDeadline(time.Now().Add(time.Millisecond * timeoutMs))
+		r.socketMutex.Unlock()
+		if err != nil {
+			return fmt.Errorf("could not set deadline on socket: %v", err)
+		}
+		n, err := socket.readBatch(*batch)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
 			}
-			n, _, addr, _ := r.socket.ReadFrom(buf) //n, ControlMessage, addr, err
-			if addr == nil {                        //Check if we had a timeout
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				//that means we did not receive a packet in 2,5s at all
 				r.checkForTimeouts()
+				continue
 			}
-			p, err := NewDataPacketRaw(buf[0:n])
+			return err
+		}
+		r.handleBatch((*batch)[:n])
+	}
+}
+
+// handleBatch parses and handles all packets that were read with one syscall. The packets are
+// parsed without copying, so the buffers can only be reused after this call returned.
+func (r *ReceiverSocket) handleBatch(msgs []ipv4.Message) {
+	r.mutex.Lock()
+	defer r.unlockAndDispatch()
+	//checking all sources for every batch is too expensive with many universes
+	if time.Since(r.lastExpire) >= expireInterval {
+		r.expireSources()
+	}
+	for _, msg := range msgs {
+		buf := msg.Buffers[0]
+		if msg.N >= 22 && getAsUint32(buf[18:22]) == vectorRootE131Extended {
+			//extended packets are not DataPackets. Only sync packets are processed for now
+			if msg.N >= 44 && getAsUint32(buf[40:44]) == vectorE131ExtendedDiscovery {
+				r.handleDiscovery(buf[:msg.N])
+				continue
+			}
+			sync, err := NewSyncPacketRaw(buf[:msg.N])
 			if err != nil {
-				continue //if the packet could not be parsed, just skip it
+				r.countMalformed(err)
+				continue
 			}
-			//send the packet to the responding handler and the other are getting nil
-			r.handle(p)
+			r.handleSyncPacket(sync)
+			continue
 		}
-		r.socket.Close()     //close the channel, if the listener is finished
-		r.stopListener = nil //set the channel to nil, so it can be used as indicator if the routine is running
-	}()
+		p, err := newDataPacketView(buf[:638], msg.N)
+		if err != nil {
+			r.countMalformed(err) //if the packet could not be parsed, just skip it
+			continue
+		}
+		r.countPacket(p, r.handlePacket(p))
+	}
 }
 
-// the handler is responsible for checking all necessary things to decide if callbacks should be invoked
-func (r *ReceiverSocket) handle(p DataPacket) {
-	r.checkForTimeouts()
-	//check if we had a change in priority to the last data we received on the universe
-	last, ok := r.lastDatas[p.Universe()]
-	if ok {
-		//check if the last packet is too long ago, then we do not have to check all other things
-		if time.Since(last.lastTime) > time.Millisecond*timeoutMs {
-			//invoke callback and store the new packet and time
-			if !bytes.Equal(last.lastPacket.Data(), p.Data()) {
-				r.invokeCallback(p)
+// handleDiscovery hands the discovery packet to the DiscoveryReceiver, if JoinDiscovery was called.
+// The mutex has to be held.
+func (r *ReceiverSocket) handleDiscovery(raw []byte) {
+	if r.discovery == nil {
+		return
+	}
+	p, err := NewDiscoveryPacketRaw(raw)
+	if err != nil {
+		r.countMalformed(err)
+		return
+	}
+	r.discovery.handle(p)
+}
+
+// checkForTimeouts removes all sources that had a timeout
+func (r *ReceiverSocket) checkForTimeouts() {
+	r.mutex.Lock()
+	defer r.unlockAndDispatch()
+	r.expireSources()
+}
+
+// handlePacket updates the source of the packet and merges the data. If the packet is not used for
+// the output, the error describes why. The mutex has to be held.
+func (r *ReceiverSocket) handlePacket(p DataPacket) error {
+	if p.PreviewData() {
+		switch r.previewMode {
+		case PreviewDrop:
+			return ErrPreview
+		case PreviewSeparate:
+			r.emit(Event{Type: EventPreview, Universe: p.Universe(), New: p.copy()})
+			return ErrPreview
+		}
+	}
+	univ, ok := r.universes[p.Universe()]
+	if !ok {
+		univ = &universeData{sources: make(map[[16]byte]*sourceData)}
+		r.universes[p.Universe()] = univ
+	}
+	src, ok := univ.sources[p.CID()]
+	if ok && !checkSequ(src.lastPacket.Sequence(), p.Sequence()) {
+		return ErrOutOfOrder
+	}
+	if p.StreamTerminated() {
+		//the source stopped transmitting, its data is not used and it is removed immediately
+		if ok {
+			src.lastPacket.set(p)
+			src.lastTime = time.Now()
+			delete(univ.sources, p.CID())
+			r.sourcesRemoved(p.Universe(), []*sourceData{src})
+		} else if len(univ.sources) == 0 {
+			delete(r.universes, p.Universe()) //the universe was only created for this packet
+		}
+		return ErrTerminated
+	}
+	if !ok {
+		src = &sourceData{}
+		univ.sources[p.CID()] = src
+	}
+	//the packet may be a view on the read buffer, so it is copied into the buffers of the source
+	src.lastPacket.set(p)
+	src.lastTime = time.Now()
+	univ.lastTime = src.lastTime
+	if !ok {
+		r.emit(Event{Type: EventSourceOnline, Universe: p.Universe(), Source: src.info()})
+	}
+	switch p.DmxStartCode() {
+	case StartCodeDMX:
+	case StartCodePerAddressPriority:
+		//per-address priorities are not synchronized and used immediately for merging
+		src.priorities = append(src.priorities[:0], p.Data()...)
+		src.prioTime = src.lastTime
+		r.update(p.Universe())
+		return nil
+	default:
+		return ErrUnknownStartCode //other alternate start codes do not contain DMX data
+	}
+	//if the packet is synchronized and we receive the sync packets, hold it until the sync arrives
+	key := syncKey{cid: p.CID(), sync: p.SyncAddress()}
+	if p.SyncAddress() != 0 && r.isSyncActive(key) {
+		src.pending.set(src.lastPacket)
+		src.hasPending = true
+		held, ok := r.syncHeld[key]
+		if !ok {
+			held = make(map[uint16]bool)
+			r.syncHeld[key] = held
+		}
+		held[p.Universe()] = true
+		return nil
+	}
+	src.hasPending = false
+	src.applied.set(src.lastPacket)
+	src.appliedTime = src.lastTime
+	src.hasApplied = true
+	r.update(p.Universe())
+	if r.isSuperseded(univ, src) {
+		return ErrSuperseded
+	}
+	return nil
+}
+
+// isSuperseded returns true, if the data of the given source is not used for merging, because
+// another source on the universe has a higher priority. With per-address priorities the data may
+// be used partly, so it is never superseded.
+func (r *ReceiverSocket) isSuperseded(univ *universeData, src *sourceData) bool {
+	if r.mergeMode != MergePriorityHTP {
+		return false
+	}
+	superseded := false
+	for _, s := range univ.sources {
+		if s.priorities != nil {
+			return false
+		}
+		if s.hasApplied && s.applied.Priority() > src.applied.Priority() {
+			superseded = true
+		}
+	}
+	return superseded
+}
+
+// countPacket updates the statistics of the universe and source of the packet. err is the reason
+// why the packet was not used, nil otherwise. The mutex has to be held.
+func (r *ReceiverSocket) countPacket(p DataPacket, err error) {
+	sources, ok := r.stats[p.Universe()]
+	if !ok {
+		sources = make(map[[16]byte]*PacketStats)
+		r.stats[p.Universe()] = sources
+	}
+	stats, ok := sources[p.CID()]
+	if !ok {
+		stats = &PacketStats{}
+		sources[p.CID()] = stats
+	}
+	stats.count(err)
+}
+
+// countMalformed counts a packet that could not be parsed by the reason of the given error.
+// The mutex has to be held.
+func (r *ReceiverSocket) countMalformed(err error) {
+	reason := err
+	var perr *PacketError
+	if errors.As(err, &perr) {
+		reason = perr.Err
+	}
+	r.malformed[reason]++
+}
+
+// handleSyncPacket applies all packets of the sending source that were held back for the sync
+// address of the given packet. The mutex has to be held.
+func (r *ReceiverSocket) handleSyncPacket(s SyncPacket) {
+	key := syncKey{cid: s.CID(), sync: s.SyncAddress()}
+	last, ok := r.lastSync[key]
+	if ok && time.Since(last.lastTime) <= time.Millisecond*timeoutMs &&
+		!checkSequ(last.sequence, s.Sequence()) {
+		return
+	}
+	r.lastSync[key] = lastSyncData{
+		lastTime: time.Now(),
+		sequence: s.Sequence(),
+	}
+	r.emit(Event{Type: EventSync, Universe: s.SyncAddress()})
+	r.applyHeld(key)
+}
+
+// applyHeld applies the pending packets of the source on all universes that wait for the sync address
+func (r *ReceiverSocket) applyHeld(key syncKey) {
+	held := r.syncHeld[key]
+	delete(r.syncHeld, key)
+	for universe := range held {
+		univ, ok := r.universes[universe]
+		if !ok {
+			continue
+		}
+		src, ok := univ.sources[key.cid]
+		if !ok || !src.hasPending || src.pending.SyncAddress() != key.sync {
+			continue
+		}
+		src.applied.set(src.pending)
+		src.appliedTime = time.Now()
+		src.hasApplied = true
+		src.hasPending = false
+		r.update(universe)
+	}
+}
+
+// isSyncActive returns true, if the source sends sync packets on the sync address.
+// Packets for sync addresses on which no sync packets arrive are processed immediately.
+func (r *ReceiverSocket) isSyncActive(key syncKey) bool {
+	last, ok := r.lastSync[key]
+	return ok && time.Since(last.lastTime) <= time.Millisecond*timeoutMs
+}
+
+// update merges the data of all sources of the universe and hands the result out via the callback,
+// if the data has changed
+func (r *ReceiverSocket) update(universe uint16) {
+	univ := r.universes[universe]
+	if !merge(r.mergeMode, univ.sources, &univ.merged) {
+		return
+	}
+	if univ.hasOutput && bytes.Equal(univ.output.Data(), univ.merged.Data()) {
+		//only the header may have changed. The output was not handed out, so it can be reused
+		univ.output.set(univ.merged)
+		return
+	}
+	//the packets of the event belong to the receivers of the event, so the output gets a new buffer
+	new := univ.merged.copy()
+	r.invokeCallback(univ, new)
+	univ.output = new.copy()
+	univ.hasOutput = true
+}
+
+// invokeCallback emits the data change of the universe.
+func (r *ReceiverSocket) invokeCallback(univ *universeData, new DataPacket) {
+	var old *DataPacket //nil, if there was no data before
+	if univ.hasOutput {
+		output := univ.output
+		old = &output
+	}
+	r.emit(Event{Type: EventDataChange, Universe: new.Universe(), Old: old, New: new})
+}
+
+// emit stores the event, it is dispatched after the mutex was unlocked. The mutex has to be held.
+func (r *ReceiverSocket) emit(e Event) {
+	r.pendingEvents = append(r.pendingEvents, e)
+}
+
+// unlockAndDispatch unlocks the mutex and dispatches all events that were emitted while it was held.
+// Every event is handed to the callback that belongs to its type in an own goroutine and is added
+// to the event channel, if it is used. Pushing to the event channel may block, so the mutex must not
+// be held while doing so. The events of concurrent calls are dispatched in the order in which the
+// mutex was held.
+func (r *ReceiverSocket) unlockAndDispatch() {
+	pending := r.pendingEvents
+	r.pendingEvents = nil
+	onChange, onTimeout := r.onChangeCallback, r.timeoutCallback
+	onOnline, onLost, onPreview := r.onSourceOnline, r.onSourceLost, r.onPreview
+	if len(pending) == 0 {
+		r.mutex.Unlock()
+		return
+	}
+	ticket := r.dispatchNext
+	r.dispatchNext++
+	r.mutex.Unlock()
+	r.waitForTurn(ticket)
+	defer r.finishTurn()
+
+	r.eventsMutex.Lock()
+	events := r.events
+	r.eventsMutex.Unlock()
+	for _, e := range pending {
+		switch e.Type {
+		case EventDataChange:
+			if onChange != nil {
+				go onChange(e.Old, e.New)
+			}
+		case EventTimeout:
+			if onTimeout != nil {
+				go onTimeout(e.Universe)
 			}
-			r.storeLastPacket(p)
-			return // we are finished with this packet
-		}
-		//we have last data for this universe, so check the priority
-		if last.lastPacket.Priority() == p.Priority() {
-			//we have the same priority
-			//check sequence:
-			if checkSequ(last.lastPacket.Sequence(), p.Sequence()) {
-				//sequence is good:; check if the data has changed. If so, then invoke callback
-				if !bytes.Equal(last.lastPacket.Data(), p.Data()) {
-					r.invokeCallback(p)
-				}
-				r.storeLastPacket(p)
+		case EventSourceOnline:
+			if onOnline != nil {
+				go onOnline(e.Source)
 			}
-		} else if last.lastPacket.Priority() < p.Priority() {
-			//priority is higher: invoke callback on data change
-			if !bytes.Equal(last.lastPacket.Data(), p.Data()) {
-				r.invokeCallback(p)
+		case EventSourceLost:
+			if onLost != nil {
+				go onLost(e.Source)
+			}
+		case EventPreview:
+			if onPreview != nil {
+				go onPreview(e.New)
 			}
-			//store the new packet regardless
-			r.storeLastPacket(p)
 		}
-	} else {
-		//store new packet and invoke callback, because we never had data on this one
-		r.invokeCallback(p)
-		r.storeLastPacket(p)
+		if events != nil {
+			events.push(e)
+		}
 	}
 }
 
-// invokeCallback calls the callback if it is present.
-func (r *ReceiverSocket) invokeCallback(new DataPacket) {
-	oldData, ok := r.lastDatas[new.Universe()]
-	var old DataPacket
-	if ok {
-		old = oldData.lastPacket
-	} else {
-		old = NewDataPacket()
+// waitForTurn blocks until the events of all previous tickets were dispatched
+func (r *ReceiverSocket) waitForTurn(ticket uint64) {
+	r.dispatchMutex.Lock()
+	defer r.dispatchMutex.Unlock()
+	if r.dispatchCond == nil {
+		r.dispatchCond = sync.NewCond(&r.dispatchMutex)
 	}
-	if r.onChangeCallback != nil {
-		go r.onChangeCallback(old, new)
+	for r.dispatched != ticket {
+		r.dispatchCond.Wait()
 	}
 }
 
-// storeLastPacket stores the packet in the lastDatas store
-func (r *ReceiverSocket) storeLastPacket(p DataPacket) {
-	r.lastDatas[p.Universe()] = lastData{
-		lastPacket: p.copy(),
-		lastTime:   time.Now(),
-	}
-	r.timeoutCalled[p.Universe()] = false
+// finishTurn lets the events of the next ticket be dispatched
+func (r *ReceiverSocket) finishTurn() {
+	r.dispatchMutex.Lock()
+	defer r.dispatchMutex.Unlock()
+	r.dispatched++
+	r.dispatchCond.Broadcast()
 }
 
-// checkForTimeouts checks all last data if a universe had a timeout. Calls the timeoutCallback.
-func (r *ReceiverSocket) checkForTimeouts() {
-	for univ, last := range r.lastDatas {
-		if time.Since(last.lastTime) > time.Millisecond*timeoutMs {
-			//timeout
-			if r.timeoutCallback != nil && !r.timeoutCalled[univ] {
-				go r.timeoutCallback(univ)
-				r.timeoutCalled[univ] = true
+// expireSources removes all sources that had a timeout and calls the timeoutCallback, if a
+// universe has no sources left. Held packets whose sync packets stopped arriving are applied
+// unsynchronized. The mutex has to be held.
+func (r *ReceiverSocket) expireSources() {
+	now := time.Now()
+	r.lastExpire = now
+	if r.discovery != nil {
+		r.discovery.checkForTimeouts()
+	}
+	for key := range r.syncHeld {
+		if !r.isSyncActive(key) {
+			r.applyHeld(key)
+		}
+	}
+	for universe, univ := range r.universes {
+		changed := false
+		var removed []*sourceData
+		for cid, src := range univ.sources {
+			if now.Sub(src.lastTime) > time.Millisecond*timeoutMs {
+				delete(univ.sources, cid)
+				removed = append(removed, src)
+			} else if src.priorities != nil && now.Sub(src.prioTime) > time.Millisecond*timeoutMs {
+				//the per-address priorities are outdated, so the priority of the packet is used
+				src.priorities = nil
+				changed = true
 			}
 		}
+		if len(removed) > 0 {
+			r.sourcesRemoved(universe, removed)
+		} else if changed {
+			r.update(universe)
+		}
+	}
+}
+
+// sourcesRemoved calls the callbacks for the given sources that were removed from the universe.
+// The remaining sources take over, if there are no sources left, the universe had a timeout.
+func (r *ReceiverSocket) sourcesRemoved(universe uint16, removed []*sourceData) {
+	for _, src := range removed {
+		r.emit(Event{Type: EventSourceLost, Universe: universe, Source: src.info()})
+	}
+	if len(r.universes[universe].sources) > 0 {
+		r.update(universe)
+	} else {
+		//the last source is gone, so this universe had a timeout and is forgotten
+		delete(r.universes, universe)
+		r.emit(Event{Type: EventTimeout, Universe: universe})
+	}
+}
+
+// info returns the information about the source based on the last received packet
+func (s *sourceData) info() SourceInfo {
+	return SourceInfo{
+		Universe:   s.lastPacket.Universe(),
+		CID:        s.lastPacket.CID(),
+		SourceName: s.lastPacket.SourceName(),
+		Priority:   s.lastPacket.Priority(),
+		Sequence:   s.lastPacket.Sequence(),
+		LastSeen:   s.lastTime,
 	}
 }
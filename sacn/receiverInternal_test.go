@@ -0,0 +1,847 @@
+package sacn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+func newTestReceiver() *ReceiverSocket {
+	return &ReceiverSocket{
+		universes: make(map[uint16]*universeData),
+		syncHeld:  make(map[syncKey]map[uint16]bool),
+		lastSync:  make(map[syncKey]lastSyncData),
+		stats:     make(map[uint16]map[[16]byte]*PacketStats),
+		malformed: make(map[error]uint64),
+	}
+}
+
+// handle handles one data packet like handleBatch, but checks for timeouts every time
+func (r *ReceiverSocket) handle(p DataPacket) {
+	r.mutex.Lock()
+	defer r.unlockAndDispatch()
+	r.expireSources()
+	r.countPacket(p, r.handlePacket(p))
+}
+
+// handleSync checks for timeouts and handles the sync packet like handleBatch
+func (r *ReceiverSocket) handleSync(s SyncPacket) {
+	r.mutex.Lock()
+	defer r.unlockAndDispatch()
+	r.expireSources()
+	r.handleSyncPacket(s)
+}
+
+func newTestPacket(universe uint16, sequ byte, data []byte) DataPacket {
+	p := NewDataPacket()
+	p.SetUniverse(universe)
+	p.SetSequence(sequ)
+	p.SetData(data)
+	return p
+}
+
+func expectPacket(t *testing.T, ch chan DataPacket, universe uint16, value byte) {
+	t.Helper()
+	select {
+	case p := <-ch:
+		if p.Universe() != universe || p.Data()[0] != value {
+			t.Errorf("Wrong packet! Was universe %v with %v; Should've been %v with %v",
+				p.Universe(), p.Data()[0], universe, value)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("No callback for universe %v", universe)
+	}
+}
+
+func expectNoPacket(t *testing.T, ch chan DataPacket) {
+	t.Helper()
+	select {
+	case p := <-ch:
+		t.Errorf("Unexpected callback for universe %v", p.Universe())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestReceiverSyncHold(t *testing.T) {
+	r := newTestReceiver()
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) {
+		ch <- new
+	})
+	//without any sync packet, synchronized data is processed immediately
+	p := newTestPacket(1, 1, []byte{1})
+	p.SetSyncAddress(10)
+	r.handle(p)
+	expectPacket(t, ch, 1, 1)
+
+	s := NewSyncPacket()
+	s.SetSyncAddress(10)
+	s.SetSequence(1)
+	r.handleSync(s)
+	expectNoPacket(t, ch)
+
+	//now the sync is active and the data has to be held back
+	p = newTestPacket(1, 2, []byte{2})
+	p.SetSyncAddress(10)
+	r.handle(p)
+	p = newTestPacket(2, 1, []byte{3})
+	p.SetSyncAddress(10)
+	r.handle(p)
+	expectNoPacket(t, ch)
+
+	s.SetSequence(2)
+	r.handleSync(s)
+	got := map[uint16]byte{}
+	for i := 0; i < 2; i++ {
+		select {
+		case p := <-ch:
+			got[p.Universe()] = p.Data()[0]
+		case <-time.After(time.Second):
+			t.Fatal("No callback after sync packet")
+		}
+	}
+	if got[1] != 2 || got[2] != 3 {
+		t.Errorf("Wrong data after sync! Was: %v", got)
+	}
+}
+
+func TestReceiverSyncPerSource(t *testing.T) {
+	r := newTestReceiver()
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) {
+		ch <- new
+	})
+	syncA := NewSyncPacket()
+	syncA.SetCID([16]byte{1})
+	syncA.SetSyncAddress(10)
+	syncA.SetSequence(1)
+	r.handleSync(syncA)
+
+	//source A sends sync packets, so its data is held back
+	a := newTestPacket(1, 1, []byte{1, 0})
+	a.SetCID([16]byte{1})
+	a.SetSyncAddress(10)
+	r.handle(a)
+	expectNoPacket(t, ch)
+	//source B uses the same sync address, but does not send sync packets
+	b := newTestPacket(2, 1, []byte{2, 0})
+	b.SetCID([16]byte{2})
+	b.SetSyncAddress(10)
+	r.handle(b)
+	expectPacket(t, ch, 2, 2)
+
+	//the sync packet of B has its own sequence and does not release the data of A
+	syncB := NewSyncPacket()
+	syncB.SetCID([16]byte{2})
+	syncB.SetSyncAddress(10)
+	syncB.SetSequence(1)
+	r.handleSync(syncB)
+	expectNoPacket(t, ch)
+	b = newTestPacket(2, 2, []byte{3, 0})
+	b.SetCID([16]byte{2})
+	b.SetSyncAddress(10)
+	r.handle(b)
+	expectNoPacket(t, ch)
+
+	syncA.SetSequence(2)
+	r.handleSync(syncA)
+	expectPacket(t, ch, 1, 1)
+	expectNoPacket(t, ch)
+	syncB.SetSequence(2)
+	r.handleSync(syncB)
+	expectPacket(t, ch, 2, 3)
+}
+
+func newTestSourcePacket(cid byte, priority byte, sequ byte, data []byte) DataPacket {
+	p := newTestPacket(1, sequ, data)
+	p.SetCID([16]byte{cid})
+	p.SetPriority(priority)
+	return p
+}
+
+func expectData(t *testing.T, ch chan DataPacket, data []byte) {
+	t.Helper()
+	select {
+	case p := <-ch:
+		if !bytes.Equal(p.Data(), data) {
+			t.Errorf("Wrong merged data! Was: %v; Should've been: %v", p.Data(), data)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("No callback for data %v", data)
+	}
+}
+
+func TestReceiverMergePriorityHTP(t *testing.T) {
+	r := newTestReceiver()
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) {
+		ch <- new
+	})
+	r.handle(newTestSourcePacket(1, 100, 1, []byte{10, 0, 30, 0}))
+	expectData(t, ch, []byte{10, 0, 30, 0})
+	//equal priority: highest takes precedence per slot
+	r.handle(newTestSourcePacket(2, 100, 1, []byte{0, 20, 5, 0}))
+	expectData(t, ch, []byte{10, 20, 30, 0})
+	//the same data again must not invoke the callback
+	r.handle(newTestSourcePacket(1, 100, 2, []byte{10, 0, 30, 0}))
+	expectNoPacket(t, ch)
+	//a higher priority source wins over all others
+	r.handle(newTestSourcePacket(3, 150, 1, []byte{1, 1, 1, 1}))
+	expectData(t, ch, []byte{1, 1, 1, 1})
+	//lower priority data does not change the output
+	r.handle(newTestSourcePacket(2, 100, 2, []byte{255, 255, 255, 255}))
+	expectNoPacket(t, ch)
+
+	//if the high priority source times out, the others take over
+	r.universes[1].sources[[16]byte{3}].lastTime = time.Now().Add(-time.Minute)
+	r.checkForTimeouts()
+	expectData(t, ch, []byte{255, 255, 255, 255})
+}
+
+func TestReceiverMergeHTPAndLTP(t *testing.T) {
+	r := newTestReceiver()
+	r.SetMergeMode(MergeHTP)
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) {
+		ch <- new
+	})
+	r.handle(newTestSourcePacket(1, 200, 1, []byte{10, 0}))
+	expectData(t, ch, []byte{10, 0})
+	r.handle(newTestSourcePacket(2, 10, 1, []byte{0, 20}))
+	expectData(t, ch, []byte{10, 20})
+
+	r = newTestReceiver()
+	r.SetMergeMode(MergeLTP)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) {
+		ch <- new
+	})
+	r.handle(newTestSourcePacket(1, 200, 1, []byte{10, 0}))
+	expectData(t, ch, []byte{10, 0})
+	time.Sleep(time.Millisecond)
+	r.handle(newTestSourcePacket(2, 10, 1, []byte{0, 20}))
+	expectData(t, ch, []byte{0, 20})
+}
+
+func TestReceiverSequencePerSource(t *testing.T) {
+	r := newTestReceiver()
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) {
+		ch <- new
+	})
+	r.handle(newTestSourcePacket(1, 100, 50, []byte{1, 0}))
+	expectData(t, ch, []byte{1, 0})
+	//another source with a lower sequence number is not out-of-order
+	r.handle(newTestSourcePacket(2, 100, 10, []byte{2, 0}))
+	expectData(t, ch, []byte{2, 0})
+	//an old packet of the first source is dropped
+	r.handle(newTestSourcePacket(1, 100, 49, []byte{3, 0}))
+	expectNoPacket(t, ch)
+}
+
+func TestReceiverPerAddressPriority(t *testing.T) {
+	r := newTestReceiver()
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) {
+		ch <- new
+	})
+	r.handle(newTestSourcePacket(1, 100, 1, []byte{10, 10, 10, 10}))
+	expectData(t, ch, []byte{10, 10, 10, 10})
+	r.handle(newTestSourcePacket(2, 100, 1, []byte{50, 50, 5, 5}))
+	expectData(t, ch, []byte{50, 50, 10, 10})
+
+	//the first source takes the first slot with a higher priority and releases the last slot
+	prio := newTestSourcePacket(1, 100, 2, []byte{150, 100, 100, 0})
+	prio.SetDmxStartCode(StartCodePerAddressPriority)
+	r.handle(prio)
+	expectData(t, ch, []byte{10, 50, 10, 5})
+
+	//the priority packet must not be treated as DMX data
+	r.handle(newTestSourcePacket(1, 100, 3, []byte{10, 10, 10, 10}))
+	expectNoPacket(t, ch)
+
+	//outdated per-address priorities are not used anymore
+	r.universes[1].sources[[16]byte{1}].prioTime = time.Now().Add(-time.Minute)
+	r.checkForTimeouts()
+	expectData(t, ch, []byte{50, 50, 10, 10})
+}
+
+func TestReceiverSourceOnlineAndLost(t *testing.T) {
+	r := newTestReceiver()
+	online := make(chan SourceInfo, 10)
+	lost := make(chan SourceInfo, 10)
+	timeout := make(chan uint16, 10)
+	r.SetOnSourceOnlineCallback(func(s SourceInfo) { online <- s })
+	r.SetOnSourceLostCallback(func(s SourceInfo) { lost <- s })
+	r.SetTimeoutCallback(func(univ uint16) { timeout <- univ })
+
+	primary := newTestSourcePacket(1, 150, 7, []byte{1, 0})
+	primary.SetSourceName("primary")
+	r.handle(primary)
+	r.handle(newTestSourcePacket(2, 100, 1, []byte{2, 0}))
+	names := map[[16]byte]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case s := <-online:
+			names[s.CID] = true
+		case <-time.After(time.Second):
+			t.Fatal("Online callback was not called")
+		}
+	}
+	if !names[[16]byte{1}] || !names[[16]byte{2}] {
+		t.Errorf("Wrong sources online! Was: %v", names)
+	}
+
+	//the primary drops, the backup is still live
+	r.universes[1].sources[[16]byte{1}].lastTime = time.Now().Add(-time.Minute)
+	r.checkForTimeouts()
+	select {
+	case s := <-lost:
+		if s.CID != [16]byte{1} || s.SourceName != "primary" || s.Priority != 150 ||
+			s.Sequence != 7 || s.Universe != 1 {
+			t.Errorf("Wrong source info! Was: %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Lost callback was not called")
+	}
+	select {
+	case <-timeout:
+		t.Error("Timeout must not be called while a source is left")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	r.universes[1].sources[[16]byte{2}].lastTime = time.Now().Add(-time.Minute)
+	r.checkForTimeouts()
+	select {
+	case univ := <-timeout:
+		if univ != 1 {
+			t.Errorf("Wrong universe timed out! Was: %v", univ)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout callback was not called")
+	}
+}
+
+func TestReceiverStreamTerminated(t *testing.T) {
+	r := newTestReceiver()
+	ch := make(chan DataPacket, 10)
+	lost := make(chan SourceInfo, 10)
+	timeout := make(chan uint16, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) { ch <- new })
+	r.SetOnSourceLostCallback(func(s SourceInfo) { lost <- s })
+	r.SetTimeoutCallback(func(univ uint16) { timeout <- univ })
+
+	r.handle(newTestSourcePacket(1, 150, 1, []byte{1, 0}))
+	expectData(t, ch, []byte{1, 0})
+	r.handle(newTestSourcePacket(2, 100, 1, []byte{2, 0}))
+	expectNoPacket(t, ch)
+
+	//the high priority source terminates, its data is skipped and the backup takes over at once
+	p := newTestSourcePacket(1, 150, 2, []byte{255, 255})
+	p.SetStreamTerminated(true)
+	r.handle(p)
+	expectData(t, ch, []byte{2, 0})
+	select {
+	case s := <-lost:
+		if s.CID != [16]byte{1} {
+			t.Errorf("Wrong source was lost! Was: %v", s.CID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Lost callback was not called")
+	}
+	if _, ok := r.universes[1].sources[[16]byte{1}]; ok {
+		t.Error("Terminated source was not removed")
+	}
+
+	p = newTestSourcePacket(2, 100, 2, []byte{2, 0})
+	p.SetStreamTerminated(true)
+	r.handle(p)
+	select {
+	case <-timeout:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout callback was not called for the last terminated source")
+	}
+	<-lost //the lost event of the second source
+	//further terminated packets of an unknown source are ignored
+	r.handle(p)
+	select {
+	case <-lost:
+		t.Error("Lost callback was called twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestReceiverPreviewMode(t *testing.T) {
+	r := newTestReceiver()
+	ch := make(chan DataPacket, 10)
+	preview := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) { ch <- new })
+	r.SetPreviewCallback(func(p DataPacket) { preview <- p })
+
+	p := newTestSourcePacket(1, 100, 1, []byte{1, 0})
+	p.SetPreviewData(true)
+	r.handle(p)
+	expectData(t, ch, []byte{1, 0})
+
+	r.SetPreviewMode(PreviewDrop)
+	p = newTestSourcePacket(2, 100, 1, []byte{2, 0})
+	p.SetPreviewData(true)
+	r.handle(p)
+	expectNoPacket(t, ch)
+
+	r.SetPreviewMode(PreviewSeparate)
+	r.handle(p)
+	expectNoPacket(t, ch)
+	expectData(t, preview, []byte{2, 0})
+}
+
+func TestReceiverRunLifecycle(t *testing.T) {
+	r, err := NewReceiverSocket("127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) { ch <- new })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() { result <- r.Run(ctx) }()
+
+	//send a packet to the receiver
+	conn, err := net.Dial("udp", "127.0.0.1:5568")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	p := newTestPacket(1, 1, []byte{42, 0})
+	conn.Write(p.getBytes())
+	expectData(t, ch, []byte{42, 0})
+
+	if err := r.Start(); err != nil {
+		t.Errorf("Start while running should do nothing, but was: %v", err)
+	}
+	if err := r.Run(ctx); err == nil {
+		t.Error("Err was nil! The receiver is already running")
+	}
+
+	//the cancellation has to stop the listener immediately
+	start := time.Now()
+	cancel()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Errorf("Wrong error! Was: %v; Should've been: %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after the cancellation")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Stopping took too long: %v", time.Since(start))
+	}
+
+	//restart after closing
+	if err := r.Close(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("Closing twice should be safe, but was: %v", err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatalf("Could not restart the receiver: %v", err)
+	}
+	p = newTestPacket(1, 2, []byte{43, 0})
+	conn.Write(p.getBytes())
+	expectData(t, ch, []byte{43, 0})
+	start = time.Now()
+	if err := r.Close(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Closing took too long: %v", time.Since(start))
+	}
+}
+
+func TestReceiverCloseBlockedEvents(t *testing.T) {
+	send := func(t *testing.T) {
+		t.Helper()
+		conn, err := net.Dial("udp", "127.0.0.1:5568")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		for universe := uint16(1); universe <= 5; universe++ {
+			p := newTestPacket(universe, 1, []byte{1, 0})
+			conn.Write(p.getBytes())
+		}
+	}
+	closeWithin := func(t *testing.T, r *ReceiverSocket) {
+		t.Helper()
+		closed := make(chan error)
+		go func() { closed <- r.Close() }()
+		select {
+		case err := <-closed:
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Close is blocked by the full event queue")
+		}
+	}
+
+	//nobody reads the events, so the listener is blocked by the full queue
+	r, err := NewReceiverSocket("127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := r.Events(1, OverflowBlock)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	send(t)
+	time.Sleep(100 * time.Millisecond)
+	closeWithin(t, r)
+	for range events {
+	}
+
+	//Close is called while the events are read
+	r, err = NewReceiverSocket("127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = r.Events(1, OverflowBlock)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	send(t)
+	for range events {
+		closeWithin(t, r)
+	}
+}
+
+// groupConn is a socket that records the joined groups and fails for IPv6 groups
+type groupConn struct {
+	batchConn
+	joined map[string]bool
+}
+
+func (c *groupConn) JoinGroup(ifi *net.Interface, group net.Addr) error {
+	if group.(*net.UDPAddr).IP.To4() == nil {
+		return fmt.Errorf("no route to %v", group)
+	}
+	c.joined[group.String()] = true
+	return nil
+}
+
+func (c *groupConn) LeaveGroup(ifi *net.Interface, group net.Addr) error {
+	delete(c.joined, group.String())
+	return nil
+}
+
+func TestJoinGroupsOptional(t *testing.T) {
+	conn := &groupConn{joined: map[string]bool{}}
+	sockets := []receiverConn{{batchConn: conn}, {batchConn: conn, ipv6: true, optional: true}}
+	if err := joinGroups(sockets, nil, 1); err != nil {
+		t.Errorf("The error of the optional IPv6 group should be ignored, but was: %v", err)
+	}
+	if !conn.joined["239.255.0.1:5568"] {
+		t.Errorf("The IPv4 group was not joined! Was: %v", conn.joined)
+	}
+	//if IPv6 was requested, the IPv4 group is left again
+	sockets[1].optional = false
+	if err := joinGroups(sockets, nil, 2); err == nil {
+		t.Error("Err was nil! The IPv6 group could not be joined")
+	}
+	if conn.joined["239.255.0.2:5568"] {
+		t.Errorf("The IPv4 group was not left after the error! Was: %v", conn.joined)
+	}
+}
+
+func TestReceiverDualStack(t *testing.T) {
+	r, err := NewReceiverSocket("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.sockets) != 2 {
+		t.Skip("IPv6 is not supported")
+	}
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) { ch <- new })
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i, addr := range []string{"127.0.0.1:5568", "[::1]:5568"} {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		p := newTestPacket(1, byte(i), []byte{byte(i + 1), 0})
+		conn.Write(p.getBytes())
+		expectData(t, ch, []byte{byte(i + 1), 0})
+	}
+	if err := r.JoinUniverse(1); err != nil {
+		t.Errorf("Could not join the IPv4 and IPv6 groups: %v", err)
+	}
+	if err := r.LeaveUniverse(1); err != nil {
+		t.Errorf("Could not leave the IPv4 and IPv6 groups: %v", err)
+	}
+}
+
+func TestReceiverReadSingle(t *testing.T) {
+	r, err := NewReceiverSocket("127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	//the fallback for systems without recvmsg reads one packet at a time
+	for i := range r.sockets {
+		r.sockets[i].batch = false
+	}
+	ch := make(chan DataPacket, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) { ch <- new })
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("udp", "127.0.0.1:5568")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := byte(1); i <= 3; i++ {
+		p := newTestPacket(uint16(i), 0, []byte{i, 0})
+		conn.Write(p.getBytes())
+	}
+	got := map[uint16]byte{}
+	for i := 0; i < 3; i++ {
+		select {
+		case p := <-ch:
+			got[p.Universe()] = p.Data()[0]
+		case <-time.After(time.Second):
+			t.Fatalf("Packets were not received! Was: %v", got)
+		}
+	}
+	if got[1] != 1 || got[2] != 2 || got[3] != 3 {
+		t.Errorf("Wrong packets! Was: %v", got)
+	}
+}
+
+func TestReceiverSnapshot(t *testing.T) {
+	r := newTestReceiver()
+	if _, ok := r.Snapshot(1); ok {
+		t.Error("Snapshot of an unknown universe should not be ok")
+	}
+	r.handle(newTestSourcePacket(1, 100, 1, []byte{1, 0}))
+	r.handle(newTestSourcePacket(2, 150, 1, []byte{2, 0}))
+	snapshot, ok := r.Snapshot(1)
+	if !ok || !snapshot.HasData {
+		t.Fatal("Snapshot should have data")
+	}
+	if !bytes.Equal(snapshot.Data.Data(), []byte{2, 0}) {
+		t.Errorf("Wrong data! Was: %v", snapshot.Data.Data())
+	}
+	if len(snapshot.Sources) != 2 || snapshot.Sources[0].CID != [16]byte{1} {
+		t.Errorf("Wrong sources! Was: %+v", snapshot.Sources)
+	}
+	if snapshot.ActiveSource == nil || snapshot.ActiveSource.CID != [16]byte{2} {
+		t.Errorf("Wrong active source! Was: %+v", snapshot.ActiveSource)
+	}
+	if time.Since(snapshot.LastSeen) > time.Second {
+		t.Errorf("Wrong last seen time! Was: %v", snapshot.LastSeen)
+	}
+	//the snapshot is a copy
+	snapshot.Data.SetData([]byte{9, 9})
+	if again, _ := r.Snapshot(1); !bytes.Equal(again.Data.Data(), []byte{2, 0}) {
+		t.Error("Snapshot data is not a copy")
+	}
+	if u := r.Universes(); len(u) != 1 || u[0] != 1 {
+		t.Errorf("Wrong universes! Was: %v", u)
+	}
+}
+
+func TestReceiverUniversesPruned(t *testing.T) {
+	r := newTestReceiver()
+	r.handle(newTestSourcePacket(1, 100, 1, []byte{1, 0}))
+	if u := r.Universes(); len(u) != 1 || u[0] != 1 {
+		t.Fatalf("Wrong universes! Was: %v", u)
+	}
+	r.universes[1].sources[[16]byte{1}].lastTime = time.Now().Add(-time.Minute)
+	r.checkForTimeouts()
+	if u := r.Universes(); len(u) != 0 {
+		t.Errorf("Universe without sources was not removed! Was: %v", u)
+	}
+	if _, ok := r.Snapshot(1); ok {
+		t.Error("Snapshot of a universe without sources should not be ok")
+	}
+
+	//a terminated packet of an unknown source does not create the universe
+	p := newTestSourcePacket(2, 100, 1, []byte{1, 0})
+	p.SetStreamTerminated(true)
+	r.handle(p)
+	if u := r.Universes(); len(u) != 0 {
+		t.Errorf("Universe was created by a terminated packet! Was: %v", u)
+	}
+
+	//the first packet after the timeout has no old data
+	ch := make(chan *DataPacket, 1)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) { ch <- old })
+	r.handle(newTestSourcePacket(1, 100, 2, []byte{2, 0}))
+	select {
+	case old := <-ch:
+		if old != nil {
+			t.Errorf("Old data should be nil after a timeout! Was: %v", old.Data())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Change callback was not called")
+	}
+}
+
+// TestReceiverConcurrent uses the ReceiverSocket from many goroutines while packets are handled.
+// Run with -race to detect unsynchronized access.
+func TestReceiverConcurrent(t *testing.T) {
+	r := newTestReceiver()
+	events := r.Events(10, OverflowDropOldest)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			r.handle(newTestSourcePacket(byte(i%3), 100, byte(i), []byte{byte(i), 0}))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) {})
+		r.SetMergeMode(MergeMode(i % 3))
+		r.SetPreviewMode(PreviewPass)
+		r.Snapshot(1)
+		r.Universes()
+		r.checkForTimeouts()
+		select {
+		case <-events:
+		default:
+		}
+	}
+	<-done
+}
+
+// newTestBatch creates one message per packet, like they are read from the socket
+func newTestBatch(packets ...DataPacket) []ipv4.Message {
+	msgs := make([]ipv4.Message, len(packets))
+	for i, p := range packets {
+		buf := make([]byte, 638)
+		msgs[i] = ipv4.Message{Buffers: [][]byte{buf}, N: copy(buf, p.getBytes())}
+	}
+	return msgs
+}
+
+func TestReceiverHandleBatch(t *testing.T) {
+	r := newTestReceiver()
+	msgs := newTestBatch(newTestPacket(1, 1, []byte{1, 0}), newTestPacket(2, 1, []byte{2, 0}))
+	r.handleBatch(msgs)
+	//the buffers are reused for the next read, this must not change the state of the receiver
+	for _, msg := range msgs {
+		msg.Buffers[0][126] = 0xFF
+	}
+	for _, universe := range []uint16{1, 2} {
+		snapshot, ok := r.Snapshot(universe)
+		if !ok || !bytes.Equal(snapshot.Data.Data(), []byte{byte(universe), 0}) {
+			t.Errorf("Wrong data on universe %v! Was: %v", universe, snapshot.Data.Data())
+		}
+	}
+}
+
+func TestReceiverChangeCallbackOld(t *testing.T) {
+	r := newTestReceiver()
+	type change struct {
+		old *DataPacket
+		new DataPacket
+	}
+	ch := make(chan change, 10)
+	r.SetOnChangeCallback(func(old *DataPacket, new DataPacket) { ch <- change{old, new} })
+
+	for sequ, value := range []byte{1, 2} {
+		r.handle(newTestPacket(1, byte(sequ), []byte{value}))
+		select {
+		case c := <-ch:
+			if sequ == 0 && c.old != nil {
+				t.Errorf("Old packet should be nil for the first data! Was: %v", c.old.Data())
+			}
+			if sequ == 1 && (c.old == nil || c.old.Data()[0] != 1) {
+				t.Errorf("Wrong old packet! Was: %v", c.old)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Change callback was not called")
+		}
+	}
+}
+
+func TestReceiverStats(t *testing.T) {
+	r := newTestReceiver()
+	r.SetPreviewMode(PreviewDrop)
+	preview := newTestSourcePacket(1, 100, 4, []byte{1})
+	preview.SetPreviewData(true)
+	unknown := newTestSourcePacket(1, 100, 5, []byte{1})
+	unknown.SetDmxStartCode(0x17)
+	terminated := newTestSourcePacket(2, 50, 2, []byte{1})
+	terminated.SetStreamTerminated(true)
+	msgs := newTestBatch(
+		newTestSourcePacket(1, 100, 1, []byte{1}),
+		newTestSourcePacket(1, 100, 2, []byte{1}),
+		newTestSourcePacket(1, 100, 1, []byte{1}), //out of order
+		newTestSourcePacket(2, 50, 1, []byte{1}),  //superseded
+		preview,
+		unknown,
+		terminated,
+	)
+	msgs = append(msgs, ipv4.Message{Buffers: [][]byte{make([]byte, 638)}, N: 100})
+	r.handleBatch(msgs)
+
+	stats := r.Stats()
+	want := []SourceStats{
+		{Universe: 1, CID: [16]byte{1}, PacketStats: PacketStats{Received: 5, OutOfOrder: 1, Preview: 1, UnknownStartCode: 1}},
+		{Universe: 1, CID: [16]byte{2}, PacketStats: PacketStats{Received: 2, Superseded: 1, Terminated: 1}},
+	}
+	if len(stats.Sources) != len(want) {
+		t.Fatalf("Wrong number of sources! Was: %v", stats.Sources)
+	}
+	for i := range want {
+		if stats.Sources[i] != want[i] {
+			t.Errorf("Wrong stats for source %v! Was: %+v, expected: %+v", i, stats.Sources[i], want[i])
+		}
+	}
+	sum := PacketStats{Received: 7, OutOfOrder: 1, Superseded: 1, Preview: 1, Terminated: 1, UnknownStartCode: 1}
+	if stats.Universes[1] != sum {
+		t.Errorf("Wrong stats for universe! Was: %+v", stats.Universes[1])
+	}
+	if stats.Malformed[ErrInvalidLength] != 1 || len(stats.Malformed) != 1 {
+		t.Errorf("Wrong malformed stats! Was: %v", stats.Malformed)
+	}
+}
+
+// BenchmarkReceiverHandleBatch measures the handling of 1000 universes, read in batches
+func BenchmarkReceiverHandleBatch(b *testing.B) {
+	r := newTestReceiver()
+	batches := make([][]ipv4.Message, 0)
+	for start := 1; start <= 1000; start += readBatchSize {
+		packets := make([]DataPacket, 0, readBatchSize)
+		for u := start; u < start+readBatchSize && u <= 1000; u++ {
+			packets = append(packets, newTestPacket(uint16(u), 0, make([]byte, 512)))
+		}
+		batches = append(batches, newTestBatch(packets...))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		for _, batch := range batches {
+			for _, msg := range batch {
+				msg.Buffers[0][111] = byte(i) //the sequence number
+			}
+			r.handleBatch(batch)
+		}
+	}
+	b.ReportMetric(float64(b.N*1000)/time.Since(start).Seconds(), "packets/s")
+}
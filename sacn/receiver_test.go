@@ -1,6 +1,7 @@
 package sacn_test
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
@@ -14,7 +15,7 @@ func ExampleReceiverSocket_unicast() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	recv.SetOnChangeCallback(func(old sacn.DataPacket, newD sacn.DataPacket) {
+	recv.SetOnChangeCallback(func(old *sacn.DataPacket, newD sacn.DataPacket) {
 		fmt.Println("data changed on", newD.Universe())
 	})
 	recv.SetTimeoutCallback(func(univ uint16) {
@@ -33,16 +34,38 @@ func ExampleReceiverSocket_multicast() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	recv.SetOnChangeCallback(func(old sacn.DataPacket, newD sacn.DataPacket) {
+	recv.SetOnChangeCallback(func(old *sacn.DataPacket, newD sacn.DataPacket) {
 		fmt.Println("data changed on", newD.Universe())
 	})
 	recv.SetTimeoutCallback(func(univ uint16) {
 		fmt.Println("timeout on", univ)
 	})
 	recv.Start()
-	recv.JoinUniverse(1)
+	if err := recv.JoinUniverse(1); err != nil {
+		log.Fatal(err)
+	}
 	time.Sleep(10 * time.Second) //only join for 10 seconds, just for testing
-	recv.LeaveUniverse(1)
+	if err := recv.LeaveUniverse(1); err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println("Leaved")
 	select {} //only that our program does not exit. Exit with Ctrl+C
 }
+
+func ExampleReceiverSocket_Run() {
+	recv, err := sacn.NewReceiverSocket("", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer recv.Close()
+	recv.SetOnChangeCallback(func(old *sacn.DataPacket, newD sacn.DataPacket) {
+		fmt.Println("data changed on", newD.Universe())
+	})
+	//stop receiving after 10 seconds, eg cancel the context on SIGTERM
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = recv.Run(ctx) //blocks until the context is canceled
+	if err != nil && err != context.DeadlineExceeded {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package sacn
+
+const (
+	vectorRootE131Extended            = 8 //VECTOR_ROOT_E131_EXTENDED
+	vectorE131ExtendedSynchronization = 1 //VECTOR_E131_EXTENDED_SYNCHRONIZATION
+	syncPacketLength                  = 49
+)
+
+// SyncPacket is an E1.31 synchronization packet. It is used by sources to tell receivers, that
+// all data that was sent with the same sync address should be applied now.
+type SyncPacket struct {
+	data []byte
+}
+
+// NewSyncPacket creates a new SyncPacket with all constant fields set
+func NewSyncPacket() SyncPacket {
+	p := SyncPacket{make([]byte, syncPacketLength)}
+	//Set constants: at index [0;16[
+	copy(p.data[0:16], constHeader)
+	//Set FAL values
+	rootFAL := calculateFal(syncPacketLength - 16)
+	copy(p.data[16:18], rootFAL[:])
+	framingFAL := calculateFal(syncPacketLength - 38)
+	copy(p.data[38:40], framingFAL[:])
+	//Set vectors:
+	copy(p.data[18:22], getAsBytes32(vectorRootE131Extended))
+	copy(p.data[40:44], getAsBytes32(vectorE131ExtendedSynchronization))
+	return p
+}
+
+// NewSyncPacketRaw creates a new SyncPacket based on the given raw bytes. If the bytes are not a
+// valid synchronization packet, a *PacketError is returned.
+func NewSyncPacketRaw(raw []byte) (SyncPacket, error) {
+	var p SyncPacket
+	if len(raw) != syncPacketLength {
+		return p, newPacketError(ErrInvalidLength, "length has to be %v, was %v", syncPacketLength, len(raw))
+	}
+	if err := checkRootLayer(raw, vectorRootE131Extended); err != nil {
+		return p, err
+	}
+	if err := checkFlagsLength(raw, 38, "framing"); err != nil {
+		return p, err
+	}
+	if v := getAsUint32(raw[40:44]); v != vectorE131ExtendedSynchronization {
+		return p, newPacketError(ErrInvalidVector, "framing vector was %v, expected %v",
+			v, vectorE131ExtendedSynchronization)
+	}
+	p.data = make([]byte, syncPacketLength)
+	copy(p.data, raw)
+	return p, nil
+}
+
+// SetCID sets the CID unique identifier
+func (s *SyncPacket) SetCID(cid [16]byte) {
+	copy(s.data[22:38], cid[:])
+}
+
+// CID returns the cid that is set for this object
+func (s *SyncPacket) CID() [16]byte {
+	tmpArray := [16]byte{}
+	copy(tmpArray[:], s.data[22:38])
+	return tmpArray
+}
+
+// SetSequence sets the sequence number of the packet
+func (s *SyncPacket) SetSequence(sequ byte) {
+	s.data[44] = sequ
+}
+
+// Sequence returns the sequence number of the packet
+func (s *SyncPacket) Sequence() byte {
+	return s.data[44]
+}
+
+// SequenceIncr increments the sequence number
+func (s *SyncPacket) SequenceIncr() {
+	s.data[44]++
+}
+
+// SetSyncAddress sets the universe on which this synchronization packet is sent
+func (s *SyncPacket) SetSyncAddress(sync uint16) {
+	copy(s.data[45:47], getAsBytes16(sync))
+}
+
+// SyncAddress returns the universe on which this synchronization packet is sent
+func (s *SyncPacket) SyncAddress() uint16 {
+	return uint16(getAsUint32(s.data[45:47]))
+}
+
+// RootVector returns the vector of the root layer
+func (s *SyncPacket) RootVector() uint32 {
+	return getAsUint32(s.data[18:22])
+}
+
+// FramingVector returns the vector of the framing layer
+func (s *SyncPacket) FramingVector() uint32 {
+	return getAsUint32(s.data[40:44])
+}
+
+func (s *SyncPacket) getBytes() []byte {
+	return s.data
+}
@@ -0,0 +1,63 @@
+package sacn
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewSyncPacket(t *testing.T) {
+	p := NewSyncPacket()
+	if len(p.getBytes()) != 49 {
+		t.Errorf("Wrong length! Was: %v; Should've been: %v", len(p.getBytes()), 49)
+	}
+	if !bytes.Equal(p.data[16:18], []byte{0x70, 0x21}) {
+		t.Errorf("Wrong root FAL! Was: %v", p.data[16:18])
+	}
+	if !bytes.Equal(p.data[38:40], []byte{0x70, 0x0b}) {
+		t.Errorf("Wrong framing FAL! Was: %v", p.data[38:40])
+	}
+	if getAsUint32(p.data[18:22]) != vectorRootE131Extended {
+		t.Errorf("Wrong root vector! Was: %v", p.data[18:22])
+	}
+	if getAsUint32(p.data[40:44]) != vectorE131ExtendedSynchronization {
+		t.Errorf("Wrong framing vector! Was: %v", p.data[40:44])
+	}
+}
+
+func TestSyncPacketFields(t *testing.T) {
+	p := NewSyncPacket()
+	cid := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	p.SetCID(cid)
+	if p.CID() != cid {
+		t.Errorf("Wrong output! Was: %v; Should've been: %v", p.CID(), cid)
+	}
+	p.SetSyncAddress(0x1234)
+	if !bytes.Equal(p.data[45:47], []byte{0x12, 0x34}) || p.SyncAddress() != 0x1234 {
+		t.Errorf("Wrong output! Was: %v; Should've been: %v", p.SyncAddress(), 0x1234)
+	}
+	p.SetSequence(255)
+	p.SequenceIncr()
+	if p.Sequence() != 0 {
+		t.Errorf("Wrong output! Was: %v; Should've been: %v", p.Sequence(), 0)
+	}
+}
+
+func TestNewSyncPacketRaw(t *testing.T) {
+	p := NewSyncPacket()
+	p.SetSyncAddress(7)
+	p.SetSequence(42)
+	raw, err := NewSyncPacketRaw(p.getBytes())
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if raw.SyncAddress() != 7 || raw.Sequence() != 42 {
+		t.Errorf("Wrong output! Was: %v, %v; Should've been: 7, 42", raw.SyncAddress(), raw.Sequence())
+	}
+	if _, err := NewSyncPacketRaw(p.getBytes()[:40]); err == nil {
+		t.Error("Err was nil! Should have been an error!")
+	}
+	data := NewDataPacket()
+	if _, err := NewSyncPacketRaw(data.getBytes()); err == nil {
+		t.Error("Err was nil! A DataPacket is not a sync packet!")
+	}
+}
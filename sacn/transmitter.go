@@ -1,113 +1,332 @@
 package sacn
 
 import (
+	"bytes"
 	"fmt"
 	"net"
+	"sort"
+	"sync"
 	"time"
+
+	"golang.org/x/net/ipv4"
 )
 
 // Transmitter : This struct is for managing the transmitting of sACN data.
 // It handles all channels and over watches what universes are already used.
+// All methods are safe for concurrent use.
 type Transmitter struct {
+	mutex     sync.Mutex //guards all fields, the goroutines of the universes hold it while sending
 	universes map[uint16]chan []byte
+	conn      *net.UDPConn //the udp socket that is shared by all universes, nil if closed
+	//pending holds all messages that were not sent yet. They are written by the sender goroutine
+	//with as few syscalls as possible
+	pending          []ipv4.Message
+	pendingUniverses []uint16      //the universe of every pending message
+	wake             chan struct{} //signals the sender goroutine that there are pending messages
+	senderDone       chan struct{} //closed, if the sender goroutine has stopped
+	//flushed holds channels that the sender goroutine closes, after it has written the messages that
+	//were pending when the channel was added
+	flushed []chan struct{}
 	//master stores the master DataPacket for all universes. Its the last send out packet
 	master            map[uint16]*DataPacket
 	destinations      map[uint16][]net.UDPAddr //holds the info about the destinations unicast or multicast
 	multicast         map[uint16]bool          //stores if an universe should be send out as multicast
-	bind              string                   //stores the string with the binding information
+	multicast6        map[uint16]bool          //stores if an universe should be send out as IPv6 multicast
 	cid               [16]byte                 //the global cid for all packets
 	sourceName        string                   //the global source name for all packets
 	keepAliveInterval time.Duration            //the minium interval a packet is sent out higher can be used for
 	priority          byte                     //the priority at which our packets are sent out and receivers use to determine which packet to use.
+	syncPackets       map[uint16]*SyncPacket   //the sync packets per sync universe, they hold the sequence numbers
+	discoveryRunning  bool                     //true, if the goroutine for the universe discovery is running
+	closing           chan struct{}            //closed, if the transmitter gets closed
+	goroutines        sync.WaitGroup           //waits for the goroutines of the universes and the discovery
+	//perAddressPriorities stores the priorities per slot that are sent with start code 0xDD
+	perAddressPriorities map[uint16][]byte
+	maxRefreshRates      map[uint16]float64          //the max packets per second per universe, 0 is unlimited
+	active               map[uint16]*activeUniverse  //the state of every activated universe
+	options              map[uint16]*universeOptions //the settings per universe that override the global ones
+	destStats            map[destKey]*DestinationStats
+	backoff              time.Duration //the duration a failed unicast destination is skipped, 0 is off
+	//onError gets called if a packet could not be sent. Gets called in own goroutine
+	onError func(err *SendError)
+}
+
+// SendError describes an error that occurred while sending a packet to a destination
+type SendError struct {
+	Universe    uint16 //the universe of the packet, DiscoveryUniverse for universe discovery packets
+	Destination net.UDPAddr
+	Err         error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("could not send universe %v to %v: %v", e.Universe, &e.Destination, e.Err)
+}
+
+// Unwrap returns the error of the socket
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// DestinationStats holds the counters of a destination to which packets were sent
+type DestinationStats struct {
+	Destination net.UDPAddr
+	Sent        uint64    //the number of packets that were sent successfully
+	Failed      uint64    //the number of packets that could not be sent
+	LastError   error     //the last error that occurred, nil if there was none
+	SkipUntil   time.Time //unicast destinations are skipped until this time after an error, see SetBackoff
+}
+
+// destKey identifies a destination without allocating a string
+type destKey struct {
+	ip   [16]byte
+	port int
+}
+
+func newDestKey(addr *net.UDPAddr) destKey {
+	k := destKey{port: addr.Port}
+	copy(k.ip[:], addr.IP.To16())
+	return k
+}
+
+const (
+	// changeRepeats is the number of times the data of a universe is repeated after it has changed,
+	// before only the keep alive packets are sent. E1.31 recommends at least three packets.
+	changeRepeats = 3
+	// defaultRepeatInterval is the interval of the repeats, if no max refresh rate is set (44 Hz)
+	defaultRepeatInterval = time.Second / 44
+	// terminationPackets is the number of packets with the stream terminated bit set, that are sent
+	// if a universe is deactivated. E1.31 requires three of them.
+	terminationPackets = 3
+)
+
+// universeOptions holds the settings of a universe that are used instead of the global settings
+type universeOptions struct {
+	priority    byte
+	hasPriority bool   //false, if the global priority is used
+	sourceName  string //empty, if the global source name is used
+	preview     bool
+	startCode   byte
+}
+
+// activeUniverse holds the state for sending out the packets of an activated universe at the
+// right time and the state of its goroutines
+type activeUniverse struct {
+	lastSent time.Time
+	dirty    bool           //true, if the data has changed but was not sent out because of the rate limit
+	repeats  int            //the number of repeats that are left after the data has changed
+	wake     chan struct{}  //wakes up the goroutine of the universe, if the timing has changed
+	stop     chan struct{}  //closed, if the universe gets deactivated
+	done     sync.WaitGroup //waits for the goroutines of the universe
+}
+
+// signal wakes up the goroutine of the universe, so it recalculates when to send the next packet
+func (u *activeUniverse) signal() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
 }
 
 // NewTransmitter creates a new Transmitter object and returns it. Only use one object for one
-// network interface. bind is a string like "192.168.2.34" or "". It is used for binding the udp connection.
-// In most cases an empty string will be sufficient. The caller is responsible for closing!
+// network interface. bind is a string like "192.168.2.34", "fe80::1%eth0", "[::1]:6000" or "". It is
+// used for binding the udp connection. In most cases an empty string will be sufficient, then IPv4
+// and IPv6 destinations can be used with one socket, if the operating system supports it.
+// All universes are sent out from one udp socket, so all packets have the same source port.
+// The caller is responsible for closing via Close!
 // If you want to use multicast, you have to provide a binding string on some operation systems (eg Windows).
-func NewTransmitter(binding string, cid [16]byte, sourceName string) (Transmitter, error) {
+func NewTransmitter(binding string, cid [16]byte, sourceName string) (*Transmitter, error) {
 	//create transmitter:
-	tx := Transmitter{
-		universes:         make(map[uint16]chan []byte),
-		master:            make(map[uint16]*DataPacket),
-		destinations:      make(map[uint16][]net.UDPAddr),
-		multicast:         make(map[uint16]bool),
-		bind:              "",
-		cid:               cid,
-		sourceName:        sourceName,
-		keepAliveInterval: time.Second * 1,
-	}
-	//create a udp address for testing, if the given bind address is possible
-	addr, err := net.ResolveUDPAddr("udp", binding)
+	tx := &Transmitter{
+		universes:            make(map[uint16]chan []byte),
+		master:               make(map[uint16]*DataPacket),
+		destinations:         make(map[uint16][]net.UDPAddr),
+		multicast:            make(map[uint16]bool),
+		multicast6:           make(map[uint16]bool),
+		cid:                  cid,
+		sourceName:           sourceName,
+		keepAliveInterval:    time.Second * 1,
+		priority:             100,
+		syncPackets:          make(map[uint16]*SyncPacket),
+		perAddressPriorities: make(map[uint16][]byte),
+		maxRefreshRates:      make(map[uint16]float64),
+		active:               make(map[uint16]*activeUniverse),
+		options:              make(map[uint16]*universeOptions),
+		destStats:            make(map[destKey]*DestinationStats),
+		closing:              make(chan struct{}),
+		wake:                 make(chan struct{}, 1),
+		senderDone:           make(chan struct{}),
+	}
+	//create the udp socket that is used for all universes
+	addr, err := net.ResolveUDPAddr("udp", joinHostPort(binding, "0"))
 	if err != nil {
 		return tx, err
 	}
 	serv, err := net.ListenUDP("udp", addr)
-	serv.Close()
 	if err != nil {
 		return tx, err
 	}
-	//if everything is ok, set the bind address string
-	tx.bind = binding
+	tx.conn = serv
+	go tx.sendLoop(serv)
 	return tx, nil
 }
 
+// Close deactivates all universes and closes the udp socket. Three packets with the stream
+// terminated bit set are sent out on every activated universe. Close returns, after all packets
+// were sent and all goroutines of the transmitter have stopped. The channels that were returned by
+// Activate must not be used for sending anymore. Calling Close twice is safe.
+func (t *Transmitter) Close() error {
+	t.mutex.Lock()
+	if t.conn == nil {
+		t.mutex.Unlock()
+		return nil
+	}
+	for universe := range t.master {
+		t.deactivate(universe)
+	}
+	close(t.closing)
+	conn := t.conn
+	t.conn = nil
+	t.signalSender()
+	t.mutex.Unlock()
+	t.goroutines.Wait()
+	//wait until the sender goroutine has written all pending messages
+	<-t.senderDone
+	return conn.Close()
+}
+
+// Deactivate stops sending out DMX data on the given universe. Three packets with the stream
+// terminated bit set are sent out and Deactivate returns, after they were written to the socket and
+// the goroutines of the universe have stopped. The channel that was returned by Activate must not be used for sending anymore.
+// Closing the channel has the same effect, but does not wait.
+func (t *Transmitter) Deactivate(universe uint16) error {
+	t.mutex.Lock()
+	state, ok := t.active[universe]
+	if !ok {
+		t.mutex.Unlock()
+		return fmt.Errorf("the given universe %v is not activated", universe)
+	}
+	t.deactivate(universe)
+	flushed := make(chan struct{})
+	t.flushed = append(t.flushed, flushed)
+	t.signalSender()
+	t.mutex.Unlock()
+	state.done.Wait()
+	<-flushed
+	return nil
+}
+
+// deactivate sends out the termination packets of the universe, removes it and stops its
+// goroutines. The mutex has to be held by the caller.
+func (t *Transmitter) deactivate(universe uint16) {
+	t.master[universe].SetStreamTerminated(true)
+	for i := 0; i < terminationPackets; i++ {
+		t.sendOut(universe)
+	}
+	close(t.active[universe].stop)
+	delete(t.master, universe)
+	delete(t.universes, universe)
+	delete(t.active, universe)
+}
+
 // Activate starts sending out DMX data on the given universe. It returns a channel that accepts
 // byte slices and transmits them to the unicast or multicast destination.
-// If you want to deactivate the universe, simply close the channel.
+// If you want to deactivate the universe, simply close the channel or use Deactivate.
+// The universe has to be in range [MinUniverse-MaxUniverse], otherwise a *UniverseError is returned.
 func (t *Transmitter) Activate(universe uint16) (chan<- []byte, error) {
-	//check if the universe is already activated
-	if t.IsActivated(universe) {
-		return nil, fmt.Errorf("the given universe %v is already activated", universe)
-	}
-	//create udp socket
-	ServerAddr, err := net.ResolveUDPAddr("udp", t.bind)
-	if err != nil {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err := checkUniverse(universe); err != nil {
 		return nil, err
 	}
-	serv, err := net.ListenUDP("udp", ServerAddr)
-	if err != nil {
-		return nil, err
+	if t.conn == nil {
+		return nil, fmt.Errorf("the transmitter is closed")
+	}
+	//check if the universe is already activated
+	if _, ok := t.universes[universe]; ok {
+		return nil, fmt.Errorf("the given universe %v is already activated", universe)
 	}
 
-	ch := make(chan []byte)
-	t.universes[universe] = ch
 	//init master packet
 	masterPacket := NewDataPacket()
 	masterPacket.SetCID(t.cid)
-	masterPacket.SetSourceName(t.sourceName)
 	masterPacket.SetUniverse(universe)
 	masterPacket.SetData(make([]byte, 512)) //set 0 data
-	if t.priority > 0x0 {
-		err = masterPacket.SetPriority(t.priority)
-		return nil, err
-	}
+	t.applyOptions(universe, &masterPacket)
+	ch := make(chan []byte)
+	t.universes[universe] = ch
 	t.master[universe] = &masterPacket
+	//the initial data is sent out immediately
+	state := &activeUniverse{
+		dirty: true,
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+	}
+	t.active[universe] = state
+	state.done.Add(2)
+	t.goroutines.Add(2)
 
-	//make goroutine that sends out every second a "keep alive" packet
+	//make goroutine that sends out the changed data, the repeats and the "keep alive" packets
 	go func() {
+		defer t.goroutines.Done()
+		defer state.done.Done()
+		timer := time.NewTimer(0)
+		defer timer.Stop()
 		for {
-			//if we have no master packet,break the loop
-			if _, ok := t.master[universe]; !ok {
-				break
+			t.mutex.Lock()
+			//if the universe was deactivated, break the loop
+			select {
+			case <-state.stop:
+				t.mutex.Unlock()
+				return
+			default:
+			}
+			wait := t.schedule(universe, state)
+			t.mutex.Unlock()
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-state.wake:
+			case <-state.stop:
 			}
-			t.sendOut(serv, universe)
-			time.Sleep(t.keepAliveInterval)
 		}
 	}()
 
+	t.startDiscovery()
+
 	go func() {
-		for i := range ch {
-			t.master[universe].SetData(i[:])
-			t.sendOut(serv, universe)
-		}
-		//if the channel was closed we send a last packet with stream terminated bit set
-		t.master[universe].SetStreamTerminated(true)
-		t.sendOut(serv, universe)
-		//if the channel was closed, we deactivate the universe
-		delete(t.master, universe)
-		delete(t.universes, universe)
-		serv.Close()
+		defer t.goroutines.Done()
+		defer state.done.Done()
+		for {
+			select {
+			case i, ok := <-ch:
+				t.mutex.Lock()
+				if !ok {
+					//if the channel was closed, we deactivate the universe, if it was not done yet
+					if t.active[universe] == state {
+						t.deactivate(universe)
+					}
+					t.mutex.Unlock()
+					return
+				}
+				//the universe may have been deactivated in the meantime
+				if t.active[universe] == state {
+					masterPacket.SetData(i[:])
+					//the data is sent out immediately, if the max refresh rate allows it
+					state.dirty = true
+					t.schedule(universe, state)
+					state.signal()
+				}
+				t.mutex.Unlock()
+			case <-state.stop:
+				return
+			}
+		}
 	}()
 
 	return ch, nil
@@ -115,6 +334,8 @@ func (t *Transmitter) Activate(universe uint16) (chan<- []byte, error) {
 
 // IsActivated checks if the given universe was activated and returns true if this is the case
 func (t *Transmitter) IsActivated(universe uint16) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	if _, ok := t.universes[universe]; ok {
 		return true
 	}
@@ -123,6 +344,8 @@ func (t *Transmitter) IsActivated(universe uint16) bool {
 
 // GetActivated returns a slice with all activated universes
 func (t *Transmitter) GetActivated() (list []uint16) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	list = make([]uint16, 0)
 	for univ := range t.universes {
 		list = append(list, univ)
@@ -132,21 +355,57 @@ func (t *Transmitter) GetActivated() (list []uint16) {
 
 // SetMulticast is for setting wether or not a universe should be send out via multicast.
 // Keep in mind, that on some operating systems you have to provide a bind address.
-func (t *Transmitter) SetMulticast(universe uint16, multicast bool) {
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (t *Transmitter) SetMulticast(universe uint16, multicast bool) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	t.multicast[universe] = multicast
+	return nil
 }
 
 // IsMulticast returns wether or not multicast is turned on for the given universe. true: on
 func (t *Transmitter) IsMulticast(universe uint16) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	return t.multicast[universe]
 }
 
+// SetMulticastIPv6 is for setting wether or not a universe should be send out via IPv6 multicast
+// to FF18::83:00:hi:lo. It can be used together with IPv4 multicast, see SetMulticast. The socket of
+// the transmitter has to support IPv6, so do not bind it to an IPv4 address.
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (t *Transmitter) SetMulticastIPv6(universe uint16, multicast bool) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.multicast6[universe] = multicast
+	return nil
+}
+
+// IsMulticastIPv6 returns wether or not IPv6 multicast is turned on for the given universe
+func (t *Transmitter) IsMulticastIPv6(universe uint16) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.multicast6[universe]
+}
+
 // SetDestinations sets a slice of destinations for the universe that is used for sending out.
 // So multiple destinations are supported. Note: the existing slice will be overwritten!
-// If you want no unicasting, just set an empty slice. If there is a string that could not be
+// If you want no unicasting, just set an empty slice. A destination can be an IPv4 or IPv6 address
+// or a hostname, with an optional port like "[::1]:5568". The default port is 5568.
+// If there is a string that could not be
 // converted to an ip-address, this one is left out and an error slice will be returned,
 // but the indices of the errors are not the same as the string indices on which the errors happened.
+// If the universe is not in range [MinUniverse-MaxUniverse], the slice only holds a *UniverseError.
 func (t *Transmitter) SetDestinations(universe uint16, destinations []string) []error {
+	if err := checkUniverse(universe); err != nil {
+		return []error{err}
+	}
 	newDest := make([]net.UDPAddr, 0)
 	errs := make([]error, 0)
 
@@ -154,14 +413,16 @@ func (t *Transmitter) SetDestinations(universe uint16, destinations []string) []
 		if dest == "" {
 			continue // continue if the string is empty
 		}
-		addr, err := net.ResolveUDPAddr("udp", dest+":5568")
+		addr, err := net.ResolveUDPAddr("udp", joinHostPort(dest, "5568"))
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
 		newDest = append(newDest, *addr)
 	}
+	t.mutex.Lock()
 	t.destinations[universe] = newDest
+	t.mutex.Unlock()
 
 	if len(errs) == 0 {
 		return nil
@@ -172,34 +433,390 @@ func (t *Transmitter) SetDestinations(universe uint16, destinations []string) []
 // Destinations returns all destinations that have been set via SetDestinations. Note: the returned
 // slice contains deep copies and no change will affect the internal slice.
 func (t *Transmitter) Destinations(universe uint16) []net.UDPAddr {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	new := make([]net.UDPAddr, len(t.destinations[universe]))
 	copy(new, t.destinations[universe])
 	return new
 }
 
-// handles sending and sequence numbering
-func (t *Transmitter) sendOut(server *net.UDPConn, universe uint16) {
+// handles sending and sequence numbering. The mutex has to be held by the caller.
+func (t *Transmitter) sendOut(universe uint16) {
 	//only send if the universe was activated
 	if _, ok := t.master[universe]; !ok {
 		return
 	}
+	if state, ok := t.active[universe]; ok {
+		state.lastSent = time.Now()
+	}
 	//increase sequence number
 	packet := t.master[universe]
 	packet.SequenceIncr()
+	t.writeOut(universe, packet.getBytes())
+	//send the per-address priorities alongside the data, they share the sequence numbers
+	if prio, ok := t.perAddressPriorities[universe]; ok {
+		packet.SequenceIncr()
+		prioPacket := packet.copy()
+		prioPacket.SetDmxStartCode(StartCodePerAddressPriority)
+		prioPacket.SetData(prio)
+		t.writeOut(universe, prioPacket.getBytes())
+	}
+}
+
+// writeOut queues the given bytes for the multicast address and all destinations of the universe.
+// The mutex has to be held by the caller.
+func (t *Transmitter) writeOut(universe uint16, bytes []byte) {
+	//the bytes are copied, because the packet may change until the sender goroutine writes it
+	buf := make([]byte, len(bytes))
+	copy(buf, bytes)
 	//check if we have to transmit via multicast
 	if t.multicast[universe] {
-		_, err := server.WriteToUDP(packet.getBytes(), generateMulticast(universe))
-		if err != nil {
-			panic(fmt.Sprintf("could not write multicast UDP to UDPConn: %v", err))
-		}
+		t.queue(universe, buf, generateMulticast(universe))
+	}
+	if t.multicast6[universe] {
+		t.queue(universe, buf, calcMulticastUDPAddr6(universe))
 	}
 	//for every destination, send out
-	for _, dest := range t.destinations[universe] {
-		_, err := server.WriteToUDP(packet.getBytes(), &dest)
+	now := time.Now()
+	for i := range t.destinations[universe] {
+		dest := t.destinations[universe][i]
+		//destinations that failed are skipped for the backoff duration
+		if stats, ok := t.destStats[newDestKey(&dest)]; ok && now.Before(stats.SkipUntil) {
+			continue
+		}
+		t.queue(universe, buf, &dest)
+	}
+}
+
+// queue adds a message to the pending messages of the sender goroutine. The mutex has to be held
+// by the caller.
+func (t *Transmitter) queue(universe uint16, buf []byte, addr *net.UDPAddr) {
+	if t.conn == nil {
+		return
+	}
+	t.pending = append(t.pending, ipv4.Message{Buffers: [][]byte{buf}, Addr: addr})
+	t.pendingUniverses = append(t.pendingUniverses, universe)
+	t.signalSender()
+}
+
+// signalSender wakes up the sender goroutine, if it is not already signaled
+func (t *Transmitter) signalSender() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sendLoop writes all pending messages in batches, until the transmitter is closed. All messages
+// that were queued before Close are still written.
+func (t *Transmitter) sendLoop(conn *net.UDPConn) {
+	defer close(t.senderDone)
+	var pc *ipv4.PacketConn
+	if batchIO {
+		pc = ipv4.NewPacketConn(conn)
+	}
+	for {
+		<-t.wake
+		t.mutex.Lock()
+		msgs, universes, flushed := t.pending, t.pendingUniverses, t.flushed
+		t.pending, t.pendingUniverses, t.flushed = nil, nil, nil
+		closed := t.conn == nil
+		t.mutex.Unlock()
+		msgs, universes = t.dropSkipped(msgs, universes)
+		for len(msgs) > 0 {
+			n, err := writeBatch(conn, pc, msgs)
+			t.report(msgs[:n], universes[:n], nil)
+			if err == nil {
+				break
+			}
+			//the failed message is skipped, so one destination can not stop the others
+			t.report(msgs[n:n+1], universes[n:n+1], err)
+			msgs, universes = t.dropSkipped(msgs[n+1:], universes[n+1:])
+		}
+		for _, ch := range flushed {
+			close(ch)
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// dropSkipped removes the messages whose destination is skipped because of the backoff. Messages
+// that were queued before the error of their destination was reported would be tried again otherwise.
+func (t *Transmitter) dropSkipped(msgs []ipv4.Message, universes []uint16) ([]ipv4.Message, []uint16) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := time.Now()
+	keptMsgs, keptUniverses := msgs[:0], universes[:0]
+	for i, msg := range msgs {
+		stats, ok := t.destStats[newDestKey(msg.Addr.(*net.UDPAddr))]
+		if ok && now.Before(stats.SkipUntil) {
+			continue
+		}
+		keptMsgs = append(keptMsgs, msg)
+		keptUniverses = append(keptUniverses, universes[i])
+	}
+	return keptMsgs, keptUniverses
+}
+
+// report updates the counters of the destinations of the given messages. If err is not nil, the
+// messages could not be sent and the error callback is called.
+func (t *Transmitter) report(msgs []ipv4.Message, universes []uint16, err error) {
+	if len(msgs) == 0 {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i, msg := range msgs {
+		addr := msg.Addr.(*net.UDPAddr)
+		stats, ok := t.destStats[newDestKey(addr)]
+		if !ok {
+			dest := net.UDPAddr{IP: append(net.IP(nil), addr.IP...), Port: addr.Port, Zone: addr.Zone}
+			stats = &DestinationStats{Destination: dest}
+			t.destStats[newDestKey(addr)] = stats
+		}
+		if err == nil {
+			stats.Sent++
+			continue
+		}
+		stats.Failed++
+		stats.LastError = err
+		if t.backoff > 0 && !addr.IP.IsMulticast() {
+			stats.SkipUntil = time.Now().Add(t.backoff)
+		}
+		if t.onError != nil {
+			go t.onError(&SendError{Universe: universes[i], Destination: *addr, Err: err})
+		}
+	}
+}
+
+// SetOnErrorCallback sets the callback that gets called, if a packet could not be sent to a
+// destination. The transmitter keeps sending to all other destinations.
+func (t *Transmitter) SetOnErrorCallback(callback func(err *SendError)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.onError = callback
+}
+
+// SetBackoff sets the duration for which a unicast destination is skipped after a packet could
+// not be sent to it. This avoids errors for every packet, if a destination is not reachable.
+// Use 0 to always send to all destinations, which is the default.
+func (t *Transmitter) SetBackoff(backoff time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.backoff = backoff
+}
+
+// DestinationStats returns the counters of all destinations to which packets were sent, sorted by
+// their address.
+func (t *Transmitter) DestinationStats() []DestinationStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	list := make([]DestinationStats, 0, len(t.destStats))
+	for _, stats := range t.destStats {
+		list = append(list, *stats)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if c := bytes.Compare(list[i].Destination.IP.To16(), list[j].Destination.IP.To16()); c != 0 {
+			return c < 0
+		}
+		return list[i].Destination.Port < list[j].Destination.Port
+	})
+	return list
+}
+
+// writeBatch writes all messages with as few syscalls as possible. If pc is not nil, the messages
+// are sent via sendmmsg. Otherwise every message is written on its own with WriteToUDP, which also
+// maps IPv4 destinations for a dual-stack socket. It returns the number of messages that were
+// written before an error occurred.
+func writeBatch(conn *net.UDPConn, pc *ipv4.PacketConn, msgs []ipv4.Message) (int, error) {
+	if pc == nil {
+		for i, msg := range msgs {
+			if _, err := conn.WriteToUDP(msg.Buffers[0], msg.Addr.(*net.UDPAddr)); err != nil {
+				return i, err
+			}
+		}
+		return len(msgs), nil
+	}
+	sent := 0
+	for sent < len(msgs) {
+		n, err := pc.WriteBatch(msgs[sent:], 0)
+		if n > 0 { //n is negative on some errors
+			sent += n
+		}
 		if err != nil {
-			panic(fmt.Sprintf("could not write unicast UDP to UDPConn: %v", err))
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// SetPerAddressPriority sets priorities per slot for the given universe. They are sent out with
+// the start code 0xDD every time the DMX data is sent. A priority of 0 means, that this source
+// does not control the slot. All values have to be in range [0-200]. Use nil to stop sending
+// per-address priorities.
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (t *Transmitter) SetPerAddressPriority(universe uint16, priorities []byte) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if priorities == nil {
+		delete(t.perAddressPriorities, universe)
+		return nil
+	}
+	for i, prio := range priorities {
+		if prio > 200 {
+			return fmt.Errorf("the priority of slot %v was %v and therefore is not in range [0-200]", i+1, prio)
+		}
+	}
+	if len(priorities) > 512 {
+		priorities = priorities[:512]
+	}
+	t.perAddressPriorities[universe] = append([]byte(nil), priorities...)
+	return nil
+}
+
+// PerAddressPriority returns a copy of the per-address priorities of the given universe.
+// nil is returned, if no per-address priorities are sent.
+func (t *Transmitter) PerAddressPriority(universe uint16) []byte {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	prio, ok := t.perAddressPriorities[universe]
+	if !ok {
+		return nil
+	}
+	return append([]byte(nil), prio...)
+}
+
+// SetSyncUniverse assigns the given sync universe to all given universes. All packets of these
+// universes carry the sync address from now on and receivers that support synchronization will
+// hold the data back until a sync packet is sent via SendSync. The universes have to be activated.
+// Use 0 as sync universe to turn off synchronization for the universes.
+func (t *Transmitter) SetSyncUniverse(sync uint16, universes ...uint16) error {
+	if sync != 0 {
+		if err := checkUniverse(sync); err != nil {
+			return err
+		}
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, univ := range universes {
+		if _, ok := t.master[univ]; !ok {
+			return fmt.Errorf("the given universe %v is not activated", univ)
+		}
+	}
+	for _, univ := range universes {
+		t.master[univ].SetSyncAddress(sync)
+	}
+	return nil
+}
+
+// SyncUniverse returns the sync universe that is used for the given universe. 0 means no sync.
+func (t *Transmitter) SyncUniverse(universe uint16) uint16 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if packet, ok := t.master[universe]; ok {
+		return packet.SyncAddress()
+	}
+	return 0
+}
+
+// SendSync sets the data for all universes in the given map and sends them out. After all
+// universes were sent, a sync packet is sent on the sync universe. All given universes have to be
+// activated and assigned to the sync universe via SetSyncUniverse. The sync packet is sent via
+// multicast and to the destinations that are set for the sync universe with SetMulticast and
+// SetDestinations. The data map may be empty, if only a sync packet should be sent.
+func (t *Transmitter) SendSync(sync uint16, data map[uint16][]byte) error {
+	if err := checkUniverse(sync); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	assigned := false
+	for _, packet := range t.master {
+		if packet.SyncAddress() == sync {
+			assigned = true
+			break
+		}
+	}
+	if !assigned {
+		return fmt.Errorf("no activated universe is assigned to the sync universe %v", sync)
+	}
+	for univ := range data {
+		if packet, ok := t.master[univ]; !ok || packet.SyncAddress() != sync {
+			return fmt.Errorf("the universe %v is not assigned to the sync universe %v", univ, sync)
 		}
 	}
+	//stage the data on all universes and send them out
+	for univ, d := range data {
+		t.master[univ].SetData(d)
+		t.sendOut(univ)
+		if state, ok := t.active[univ]; ok {
+			state.dirty = false
+			state.repeats = changeRepeats
+			state.signal()
+		}
+	}
+	//send the sync packet with its own sequence number
+	packet, ok := t.syncPackets[sync]
+	if !ok {
+		p := NewSyncPacket()
+		p.SetCID(t.cid)
+		p.SetSyncAddress(sync)
+		packet = &p
+		t.syncPackets[sync] = packet
+	}
+	packet.SequenceIncr()
+	t.writeOut(sync, packet.getBytes())
+	return nil
+}
+
+// startDiscovery starts a goroutine that sends out the universe discovery packets every 10 seconds
+// on the discovery universe. The goroutine stops, if no universe is activated anymore or the
+// transmitter is closed. The mutex has to be held by the caller.
+func (t *Transmitter) startDiscovery() {
+	if t.discoveryRunning {
+		return
+	}
+	t.discoveryRunning = true
+	t.goroutines.Add(1)
+	go func() {
+		defer t.goroutines.Done()
+		for {
+			t.mutex.Lock()
+			if len(t.universes) == 0 {
+				t.discoveryRunning = false
+				t.mutex.Unlock()
+				break
+			}
+			universes := make([]uint16, 0, len(t.universes))
+			ipv6 := false //the discovery packets are sent via IPv6, if any universe is sent that way
+			for univ := range t.universes {
+				universes = append(universes, univ)
+				ipv6 = ipv6 || t.multicast6[univ]
+			}
+			for _, packet := range newDiscoveryPackets(t.cid, t.sourceName, universes) {
+				t.queue(DiscoveryUniverse, packet.getBytes(), generateMulticast(DiscoveryUniverse))
+				if ipv6 {
+					t.queue(DiscoveryUniverse, packet.getBytes(), calcMulticastUDPAddr6(DiscoveryUniverse))
+				}
+			}
+			t.mutex.Unlock()
+			timer := time.NewTimer(discoveryInterval)
+			select {
+			case <-timer.C:
+			case <-t.closing:
+				timer.Stop()
+				t.mutex.Lock()
+				t.discoveryRunning = false
+				t.mutex.Unlock()
+				return
+			}
+		}
+	}()
 }
 
 // Allows the user to set a different interval than the internal default
@@ -207,14 +824,242 @@ func (t *Transmitter) sendOut(server *net.UDPConn, universe uint16) {
 // to the outputs. (e.g. a much higher interval for less dynamically
 // changing lighting and lower overall network traffic.)
 func (t *Transmitter) SetKeepAlive(interval time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	t.keepAliveInterval = interval
+	for _, state := range t.active {
+		state.signal()
+	}
+}
+
+// SetMaxRefreshRate limits the packets per second that are sent out on the given universe. If data
+// is sent faster over the channel, only the latest data is sent out at this rate. After the data
+// has changed, it is repeated three times at this rate and then only at the keep alive interval.
+// Use 0 for no limit, which is the default. Note that DMX itself is limited to about 44 Hz.
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (t *Transmitter) SetMaxRefreshRate(universe uint16, rate float64) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if rate <= 0 {
+		delete(t.maxRefreshRates, universe)
+	} else {
+		t.maxRefreshRates[universe] = rate
+	}
+	if state, ok := t.active[universe]; ok {
+		state.signal()
+	}
+	return nil
+}
+
+// MaxRefreshRate returns the max packets per second of the given universe. 0 means no limit.
+func (t *Transmitter) MaxRefreshRate(universe uint16) float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.maxRefreshRates[universe]
+}
+
+// schedule sends out the packet of the universe, if it is due, and returns the duration until the
+// next packet is due. The mutex has to be held by the caller.
+func (t *Transmitter) schedule(universe uint16, state *activeUniverse) time.Duration {
+	next := t.nextSend(universe, state)
+	if time.Now().Before(next) {
+		return time.Until(next)
+	}
+	if state.dirty {
+		state.dirty = false
+		state.repeats = changeRepeats
+	} else if state.repeats > 0 {
+		state.repeats--
+	}
+	t.sendOut(universe)
+	return time.Until(t.nextSend(universe, state))
+}
+
+// nextSend returns the time at which the next packet of the universe is due. Changed data is sent
+// as soon as the max refresh rate allows it, followed by the repeats and the keep alive packets.
+// The mutex has to be held by the caller.
+func (t *Transmitter) nextSend(universe uint16, state *activeUniverse) time.Time {
+	interval := time.Duration(0)
+	if rate, ok := t.maxRefreshRates[universe]; ok {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+	switch {
+	case state.dirty:
+		return state.lastSent.Add(interval)
+	case state.repeats > 0:
+		if interval == 0 {
+			interval = defaultRepeatInterval
+		}
+		return state.lastSent.Add(interval)
+	default:
+		return state.lastSent.Add(t.keepAliveInterval)
+	}
 }
 
 // Allows the caller to set a priority on the sACN packets to be used in
 // situations when a destination receives data from multiple sources and
-// needs to decide which one to ignore.
-func (t *Transmitter) SetPriority(prio byte) {
+// needs to decide which one to ignore. The value must be in range [0-200], the default is 100.
+// It is used for all universes that have no own priority, see SetUniversePriority.
+func (t *Transmitter) SetPriority(prio byte) error {
+	if prio > 200 {
+		return fmt.Errorf("the priority was %v and therefore is not in range [0-200]", prio)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	t.priority = prio
+	t.updateOptions()
+	return nil
+}
+
+// SetUniversePriority sets the priority of the given universe, which is used instead of the global
+// priority. The value must be in range [0-200]. It can be changed while the universe is activated
+// and is used for the next packet that is sent out.
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (t *Transmitter) SetUniversePriority(universe uint16, prio byte) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	if prio > 200 {
+		return fmt.Errorf("the priority was %v and therefore is not in range [0-200]", prio)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	opts := t.universeOptions(universe)
+	opts.priority = prio
+	opts.hasPriority = true
+	t.updateOptions()
+	return nil
+}
+
+// UniversePriority returns the priority that is used for the given universe
+func (t *Transmitter) UniversePriority(universe uint16) byte {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if opts, ok := t.options[universe]; ok && opts.hasPriority {
+		return opts.priority
+	}
+	return t.priority
+}
+
+// SetUniverseSourceName sets the source name of the given universe, which is used instead of the
+// source name of the transmitter. Use an empty string to use the source name of the transmitter.
+// Note that only the first 64 characters are used! It can be changed while the universe is
+// activated and is used for the next packet that is sent out.
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (t *Transmitter) SetUniverseSourceName(universe uint16, sourceName string) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.universeOptions(universe).sourceName = sourceName
+	t.updateOptions()
+	return nil
+}
+
+// UniverseSourceName returns the source name that is used for the given universe
+func (t *Transmitter) UniverseSourceName(universe uint16) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if opts, ok := t.options[universe]; ok && opts.sourceName != "" {
+		return opts.sourceName
+	}
+	return t.sourceName
+}
+
+// SetPreviewData sets the preview_data flag for all packets of the given universe. Receivers should
+// only use this data for visualisation and not for live output. It can be changed while the
+// universe is activated and is used for the next packet that is sent out.
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (t *Transmitter) SetPreviewData(universe uint16, preview bool) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.universeOptions(universe).preview = preview
+	t.updateOptions()
+	return nil
+}
+
+// IsPreviewData returns wether the packets of the given universe have the preview_data flag set
+func (t *Transmitter) IsPreviewData(universe uint16) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if opts, ok := t.options[universe]; ok {
+		return opts.preview
+	}
+	return false
+}
+
+// SetStartCode sets the DMX start code for the data of the given universe. The default is
+// StartCodeDMX (0x00). Receivers only use data with other start codes, if they support them.
+// It can be changed while the universe is activated and is used for the next packet that is sent
+// out. The per-address priorities are always sent with StartCodePerAddressPriority.
+// If the universe is not in range [MinUniverse-MaxUniverse], a *UniverseError is returned.
+func (t *Transmitter) SetStartCode(universe uint16, startCode byte) error {
+	if err := checkUniverse(universe); err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.universeOptions(universe).startCode = startCode
+	t.updateOptions()
+	return nil
+}
+
+// StartCode returns the DMX start code that is used for the data of the given universe
+func (t *Transmitter) StartCode(universe uint16) byte {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if opts, ok := t.options[universe]; ok {
+		return opts.startCode
+	}
+	return StartCodeDMX
+}
+
+// universeOptions returns the options of the universe and creates them, if they do not exist.
+// The mutex has to be held by the caller.
+func (t *Transmitter) universeOptions(universe uint16) *universeOptions {
+	opts, ok := t.options[universe]
+	if !ok {
+		opts = &universeOptions{}
+		t.options[universe] = opts
+	}
+	return opts
+}
+
+// applyOptions sets the options of the universe or the global settings on the given packet.
+// The mutex has to be held by the caller.
+func (t *Transmitter) applyOptions(universe uint16, p *DataPacket) {
+	opts, ok := t.options[universe]
+	if !ok {
+		opts = &universeOptions{}
+	}
+	prio := t.priority
+	if opts.hasPriority {
+		prio = opts.priority
+	}
+	//can not fail, the priorities were validated by SetPriority and SetUniversePriority
+	_ = p.SetPriority(prio)
+	sourceName := t.sourceName
+	if opts.sourceName != "" {
+		sourceName = opts.sourceName
+	}
+	p.SetSourceName(sourceName)
+	p.SetPreviewData(opts.preview)
+	p.SetDmxStartCode(opts.startCode)
+}
+
+// updateOptions applies the current options on the packets of all activated universes.
+// The mutex has to be held by the caller.
+func (t *Transmitter) updateOptions() {
+	for universe, packet := range t.master {
+		t.applyOptions(universe, packet)
+	}
 }
 
 func generateMulticast(universe uint16) *net.UDPAddr {
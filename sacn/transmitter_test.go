@@ -0,0 +1,708 @@
+package sacn
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// listenTest opens a udp socket on a free local port for receiving the transmitted packets. Use
+// conn.LocalAddr().String() as destination.
+func listenTest(t *testing.T) *net.UDPConn {
+	t.Helper()
+	addr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		t.Fatalf("could not listen on %v: %v", addr, err)
+	}
+	return conn
+}
+
+func TestTransmitterInvalidUniverse(t *testing.T) {
+	trans, err := NewTransmitter("", [16]byte{1}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	for _, universe := range []uint16{0, 64000, DiscoveryUniverse} {
+		if _, err := trans.Activate(universe); !errors.Is(err, ErrInvalidUniverse) {
+			t.Errorf("Activate(%v) should fail! Was: %v", universe, err)
+		}
+		if errs := trans.SetDestinations(universe, []string{"127.0.0.1"}); len(errs) != 1 ||
+			!errors.Is(errs[0], ErrInvalidUniverse) {
+			t.Errorf("SetDestinations(%v) should fail! Was: %v", universe, errs)
+		}
+		setters := map[string]error{
+			"SetMulticast":          trans.SetMulticast(universe, true),
+			"SetPerAddressPriority": trans.SetPerAddressPriority(universe, []byte{100}),
+			"SetMaxRefreshRate":     trans.SetMaxRefreshRate(universe, 10),
+			"SetUniversePriority":   trans.SetUniversePriority(universe, 100),
+			"SetUniverseSourceName": trans.SetUniverseSourceName(universe, "name"),
+			"SetPreviewData":        trans.SetPreviewData(universe, true),
+			"SetStartCode":          trans.SetStartCode(universe, 0x17),
+		}
+		for name, err := range setters {
+			if !errors.Is(err, ErrInvalidUniverse) {
+				t.Errorf("%v(%v) should fail! Was: %v", name, universe, err)
+			}
+		}
+	}
+	if len(trans.GetActivated()) != 0 {
+		t.Errorf("No universe should be activated! Was: %v", trans.GetActivated())
+	}
+	if len(trans.Destinations(0)) != 0 || trans.MaxRefreshRate(0) != 0 || trans.IsPreviewData(0) {
+		t.Error("Settings of an invalid universe should not be stored")
+	}
+}
+
+func TestTransmitterIPv6(t *testing.T) {
+	addr, _ := net.ResolveUDPAddr("udp6", "[::1]:0")
+	conn, err := net.ListenUDP("udp6", addr)
+	if err != nil {
+		t.Skipf("IPv6 is not supported: %v", err)
+	}
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{25}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	if errs := trans.SetDestinations(1, []string{"::1", "[::1]:6000", "127.0.0.1"}); errs != nil {
+		t.Fatal(errs)
+	}
+	dests := trans.Destinations(1)
+	if len(dests) != 3 || dests[0].Port != 5568 || dests[1].Port != 6000 || !dests[1].IP.Equal(net.IPv6loopback) {
+		t.Errorf("Wrong destinations! Was: %v", dests)
+	}
+	trans.SetDestinations(1, []string{conn.LocalAddr().String()})
+	if err := trans.SetMulticastIPv6(1, true); err != nil || !trans.IsMulticastIPv6(1) {
+		t.Fatalf("IPv6 multicast should be on! Was: %v", err)
+	}
+	ch, err := trans.Activate(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch <- []byte{6}
+
+	buf := make([]byte, 638)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("packet was not received: %v", err)
+		}
+		p, err := NewDataPacketRaw(buf[:n])
+		if err == nil && p.CID() == [16]byte{25} && len(p.Data()) > 0 && p.Data()[0] == 6 {
+			break
+		}
+	}
+	//the multicast packets are either sent or fail, depending on the network
+	if err := trans.Deactivate(1); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, stats := range trans.DestinationStats() {
+		found = found || stats.Destination.IP.Equal(net.ParseIP("ff18::83:0:1"))
+	}
+	if !found {
+		t.Errorf("No packets were sent to the IPv6 multicast address! Was: %v", trans.DestinationStats())
+	}
+}
+
+func TestTransmitterSendSync(t *testing.T) {
+	conn := listenTest(t)
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{1}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	for _, univ := range []uint16{1, 2} {
+		ch, err := trans.Activate(univ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer close(ch)
+		trans.SetDestinations(univ, []string{conn.LocalAddr().String()})
+	}
+	if err := trans.SetSyncUniverse(5, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := trans.SetSyncUniverse(5, 3); err == nil {
+		t.Error("Err was nil! Universe 3 is not activated")
+	}
+	trans.SetDestinations(5, []string{conn.LocalAddr().String()})
+	err = trans.SendSync(5, map[uint16][]byte{1: {11}, 2: {22}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[uint16]byte{}
+	buf := make([]byte, 638)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("sync packet was not received: %v", err)
+		}
+		if sync, err := NewSyncPacketRaw(buf[:n]); err == nil {
+			if sync.SyncAddress() != 5 || sync.Sequence() != 1 {
+				t.Errorf("Wrong sync packet! Address: %v Sequence: %v", sync.SyncAddress(), sync.Sequence())
+			}
+			break
+		}
+		p, err := NewDataPacketRaw(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.SyncAddress() != 5 {
+			t.Errorf("Wrong sync address! Was: %v; Should've been: 5", p.SyncAddress())
+		}
+		got[p.Universe()] = p.Data()[0]
+	}
+	if got[1] != 11 || got[2] != 22 {
+		t.Errorf("Staged data was not sent before the sync packet! Was: %v", got)
+	}
+	if err := trans.SendSync(5, map[uint16][]byte{3: {1}}); err == nil {
+		t.Error("Err was nil! Universe 3 is not assigned to the sync universe")
+	}
+}
+
+func TestTransmitterPerAddressPriority(t *testing.T) {
+	conn := listenTest(t)
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{6}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	if err := trans.SetPerAddressPriority(1, []byte{201}); err == nil {
+		t.Error("Err was nil! Priority 201 is not allowed")
+	}
+	if err := trans.SetPerAddressPriority(1, []byte{100, 0, 200}); err != nil {
+		t.Fatal(err)
+	}
+	trans.SetDestinations(1, []string{conn.LocalAddr().String()})
+	ch, err := trans.Activate(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(ch)
+
+	buf := make([]byte, 638)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var data DataPacket
+	for i := 0; i < 2; i++ {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("packets were not received: %v", err)
+		}
+		p, err := NewDataPacketRaw(buf[:n])
+		if err != nil || p.CID() != [16]byte{6} {
+			i--
+			continue //skip packets of other tests
+		}
+		if i == 0 {
+			data = p
+			if p.DmxStartCode() != StartCodeDMX {
+				t.Errorf("First packet should be DMX data! Start code was: %v", p.DmxStartCode())
+			}
+			continue
+		}
+		if p.DmxStartCode() != StartCodePerAddressPriority {
+			t.Errorf("Second packet should be per-address priority! Start code was: %v", p.DmxStartCode())
+		}
+		if !bytes.Equal(p.Data()[:3], []byte{100, 0, 200}) {
+			t.Errorf("Wrong priorities! Was: %v", p.Data()[:3])
+		}
+		if p.Sequence() != data.Sequence()+1 {
+			t.Errorf("Wrong sequence! Was: %v; Should've been: %v", p.Sequence(), data.Sequence()+1)
+		}
+	}
+}
+
+// TestTransmitterConcurrent uses the Transmitter from many goroutines. Run with -race to detect
+// unsynchronized access.
+func TestTransmitterConcurrent(t *testing.T) {
+	trans, err := NewTransmitter("", [16]byte{12}, "concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	trans.SetKeepAlive(time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			universe := uint16(100 + i%4)
+			for j := 0; j < 50; j++ {
+				ch, err := trans.Activate(universe)
+				if err == nil {
+					ch <- []byte{byte(j)}
+					trans.SetMulticast(universe, j%2 == 0)
+					trans.SetDestinations(universe, []string{"127.0.0.1"})
+					ch <- []byte{byte(j), 1}
+					close(ch)
+				}
+				trans.SetMulticast(universe+10, true)
+				trans.SetDestinations(universe+10, []string{"127.0.0.1"})
+				trans.IsMulticast(universe)
+				trans.Destinations(universe)
+				trans.IsActivated(universe)
+				trans.GetActivated()
+				trans.SetPerAddressPriority(universe, []byte{100})
+				trans.SetSyncUniverse(200, universe)
+				trans.SendSync(200, nil)
+				trans.SetKeepAlive(time.Millisecond)
+				if _, err := trans.Activate(universe + 20); err == nil {
+					trans.Deactivate(universe + 20)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	//wait for the goroutines of the closed channels
+	for i := 0; i < 100 && len(trans.GetActivated()) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(trans.GetActivated()) != 0 {
+		t.Errorf("All universes should have been deactivated, but were: %v", trans.GetActivated())
+	}
+}
+
+func TestTransmitterSharedSocket(t *testing.T) {
+	conn := listenTest(t)
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{14}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, univ := range []uint16{1, 2, 3} {
+		ch, err := trans.Activate(univ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer close(ch)
+		trans.SetDestinations(univ, []string{conn.LocalAddr().String()})
+		ch <- []byte{byte(univ)}
+	}
+
+	//read packets of our transmitter, until all universes were seen
+	read := func(check func(p DataPacket) bool) map[uint16]*net.UDPAddr {
+		t.Helper()
+		got := map[uint16]*net.UDPAddr{}
+		buf := make([]byte, 638)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for len(got) < 3 {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				t.Fatalf("packets were not received: %v", err)
+			}
+			p, err := NewDataPacketRaw(buf[:n])
+			if err != nil || p.CID() != [16]byte{14} || !check(p) {
+				continue //skip packets of other tests
+			}
+			got[p.Universe()] = addr
+		}
+		return got
+	}
+	got := read(func(p DataPacket) bool { return p.Data()[0] == byte(p.Universe()) })
+	if got[1].Port != got[2].Port || got[1].Port != got[3].Port {
+		t.Errorf("Universes were not sent from the same socket! Ports: %v %v %v",
+			got[1].Port, got[2].Port, got[3].Port)
+	}
+
+	if err := trans.Close(); err != nil {
+		t.Fatal(err)
+	}
+	read(func(p DataPacket) bool { return p.StreamTerminated() })
+	if len(trans.GetActivated()) != 0 {
+		t.Errorf("All universes should have been deactivated, but were: %v", trans.GetActivated())
+	}
+	if _, err := trans.Activate(4); err == nil {
+		t.Error("Err was nil! The transmitter is closed")
+	}
+	if err := trans.Close(); err != nil {
+		t.Errorf("Closing twice should not fail: %v", err)
+	}
+}
+
+// benchmarkMessages creates one data packet per universe, that is sent to a local socket
+func benchmarkMessages(b *testing.B, universes int) (*net.UDPConn, *net.UDPConn, []ipv4.Message) {
+	b.Helper()
+	sink, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	msgs := make([]ipv4.Message, universes)
+	for i := range msgs {
+		p := NewDataPacket()
+		p.SetUniverse(uint16(i + 1))
+		p.SetData(make([]byte, 512))
+		msgs[i] = ipv4.Message{Buffers: [][]byte{p.getBytes()}, Addr: sink.LocalAddr()}
+	}
+	return sink, conn, msgs
+}
+
+// BenchmarkWriteSingle measures the packets per second with one write per packet
+func BenchmarkWriteSingle(b *testing.B) {
+	sink, conn, msgs := benchmarkMessages(b, 1000)
+	defer sink.Close()
+	defer conn.Close()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		for _, msg := range msgs {
+			if _, err := conn.WriteToUDP(msg.Buffers[0], msg.Addr.(*net.UDPAddr)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*len(msgs))/time.Since(start).Seconds(), "packets/s")
+}
+
+// BenchmarkWriteBatch measures the packets per second with batched writes, like the Transmitter
+func BenchmarkWriteBatch(b *testing.B) {
+	sink, conn, msgs := benchmarkMessages(b, 1000)
+	defer sink.Close()
+	defer conn.Close()
+	pc := ipv4.NewPacketConn(conn)
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, err := writeBatch(conn, pc, msgs); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N*len(msgs))/time.Since(start).Seconds(), "packets/s")
+}
+
+func TestWriteBatchSingle(t *testing.T) {
+	sink := listenTest(t)
+	defer sink.Close()
+	//an unbound socket is dual-stack, the IPv4 destination has to be mapped by WriteToUDP
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	msgs := []ipv4.Message{
+		{Buffers: [][]byte{{1}}, Addr: sink.LocalAddr()},
+		{Buffers: [][]byte{{2}}, Addr: sink.LocalAddr()},
+	}
+	if n, err := writeBatch(conn, nil, msgs); n != 2 || err != nil {
+		t.Fatalf("Messages were not written! Was: %v %v", n, err)
+	}
+	buf := make([]byte, 10)
+	sink.SetReadDeadline(time.Now().Add(time.Second))
+	for i := byte(1); i <= 2; i++ {
+		n, _, err := sink.ReadFromUDP(buf)
+		if err != nil || n != 1 || buf[0] != i {
+			t.Errorf("Wrong message! Was: %v %v", buf[:n], err)
+		}
+	}
+}
+
+func TestTransmitterMaxRefreshRate(t *testing.T) {
+	conn := listenTest(t)
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{17}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	trans.SetMaxRefreshRate(1, 20) //one packet every 50ms
+	if trans.MaxRefreshRate(1) != 20 {
+		t.Errorf("Wrong max refresh rate! Was: %v", trans.MaxRefreshRate(1))
+	}
+	trans.SetDestinations(1, []string{conn.LocalAddr().String()})
+	ch, err := trans.Activate(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(ch)
+	//the burst is coalesced to the latest frame
+	for i := byte(1); i <= 10; i++ {
+		ch <- []byte{i}
+	}
+
+	//the initial data (that may already be the first frame), the latest frame and its three
+	//repeats. The keep alive follows after 1s.
+	got := make([]byte, 0)
+	buf := make([]byte, 638)
+	conn.SetReadDeadline(time.Now().Add(600 * time.Millisecond))
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		p, err := NewDataPacketRaw(buf[:n])
+		if err != nil || p.CID() != [16]byte{17} {
+			continue //skip packets of other tests
+		}
+		got = append(got, p.Data()[0])
+	}
+	if len(got) != 5 || got[0] > 1 || !bytes.Equal(got[1:], []byte{10, 10, 10, 10}) {
+		t.Errorf("Wrong packets! Was: %v; Should've been: [0 10 10 10 10]", got)
+	}
+}
+
+func TestTransmitterDeactivate(t *testing.T) {
+	conn := listenTest(t)
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{18}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	for _, univ := range []uint16{1, 2} {
+		trans.SetDestinations(univ, []string{conn.LocalAddr().String()})
+	}
+	if _, err := trans.Activate(1); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := trans.Activate(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//expectTerminated reads the packets of the universe until the stream was terminated
+	expectTerminated := func(universe uint16) {
+		t.Helper()
+		terminated := 0
+		var last byte
+		buf := make([]byte, 638)
+		conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				break
+			}
+			p, err := NewDataPacketRaw(buf[:n])
+			if err != nil || p.CID() != [16]byte{18} || p.Universe() != universe {
+				continue //skip packets of other tests and universes
+			}
+			if terminated > 0 && !p.StreamTerminated() {
+				t.Error("A packet was sent after the stream was terminated")
+			}
+			if p.StreamTerminated() {
+				if terminated > 0 && p.Sequence() != last+1 {
+					t.Errorf("Wrong sequence! Was: %v; Should've been: %v", p.Sequence(), last+1)
+				}
+				terminated++
+				last = p.Sequence()
+			}
+		}
+		if terminated != 3 {
+			t.Errorf("Universe %v: %v packets with the stream terminated bit were sent instead of 3",
+				universe, terminated)
+		}
+	}
+
+	if err := trans.Deactivate(1); err != nil {
+		t.Fatal(err)
+	}
+	if trans.IsActivated(1) {
+		t.Error("Universe 1 should have been deactivated")
+	}
+	expectTerminated(1)
+	if err := trans.Deactivate(1); err == nil {
+		t.Error("Err was nil! Universe 1 is not activated")
+	}
+
+	close(ch)
+	expectTerminated(2)
+	if trans.IsActivated(2) {
+		t.Error("Universe 2 should have been deactivated")
+	}
+	if _, err := trans.Activate(2); err != nil {
+		t.Errorf("Universe 2 could not be activated again: %v", err)
+	}
+}
+
+func TestTransmitterDeactivateFlushed(t *testing.T) {
+	conn := listenTest(t)
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{20}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	trans.SetDestinations(1, []string{conn.LocalAddr().String()})
+	if _, err := trans.Activate(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := trans.Deactivate(1); err != nil {
+		t.Fatal(err)
+	}
+	//all packets of the universe were written, when Deactivate returned
+	stats := trans.DestinationStats()
+	if len(stats) == 0 || stats[0].Destination.String() != conn.LocalAddr().String() {
+		t.Fatalf("No packets were sent! Was: %v", stats)
+	}
+	received := uint64(0)
+	buf := make([]byte, 638)
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	for {
+		if _, _, err := conn.ReadFromUDP(buf); err != nil {
+			break
+		}
+		received++
+	}
+	if received != stats[0].Sent || received < terminationPackets {
+		t.Errorf("%v packets were sent when Deactivate returned, but %v were received",
+			stats[0].Sent, received)
+	}
+}
+
+func TestTransmitterSendError(t *testing.T) {
+	conn := listenTest(t)
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{19}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	errs := make(chan *SendError, 10)
+	trans.SetOnErrorCallback(func(err *SendError) { errs <- err })
+	trans.SetBackoff(time.Hour)
+	//sending to port 0 fails
+	trans.SetDestinations(1, []string{conn.LocalAddr().String()})
+	trans.mutex.Lock()
+	trans.destinations[1] = append(trans.destinations[1], net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	trans.mutex.Unlock()
+	ch, err := trans.Activate(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(ch)
+	ch <- []byte{1}
+
+	select {
+	case e := <-errs:
+		if e.Universe != 1 || e.Destination.Port != 0 || e.Err == nil {
+			t.Errorf("Wrong error! Was: %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No error was reported")
+	}
+	//the other destination still receives the packets
+	buf := make([]byte, 638)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("packets were not received: %v", err)
+		}
+		if p, err := NewDataPacketRaw(buf[:n]); err == nil && p.CID() == [16]byte{19} {
+			break
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond) //wait for the repeats
+	//the first entries are the unicast destinations, the discovery multicast address follows
+	stats := trans.DestinationStats()
+	if len(stats) < 2 {
+		t.Fatalf("Wrong number of destinations! Was: %v", stats)
+	}
+	//because of the backoff, port 0 was only tried once
+	if stats[0].Destination.Port != 0 || stats[0].Failed != 1 || stats[0].LastError == nil ||
+		stats[0].SkipUntil.IsZero() {
+		t.Errorf("Wrong stats for port 0! Was: %+v", stats[0])
+	}
+	if stats[1].Destination.String() != conn.LocalAddr().String() || stats[1].Sent == 0 ||
+		stats[1].Failed != 0 {
+		t.Errorf("Wrong stats for %v! Was: %+v", conn.LocalAddr(), stats[1])
+	}
+}
+
+func TestTransmitterUniverseOptions(t *testing.T) {
+	conn := listenTest(t)
+	defer conn.Close()
+
+	trans, err := NewTransmitter("", [16]byte{20}, "global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trans.Close()
+	if err := trans.SetPriority(201); err == nil {
+		t.Error("Err was nil! Priority 201 is not allowed")
+	}
+	if err := trans.SetPriority(150); err != nil {
+		t.Fatal(err)
+	}
+	trans.SetKeepAlive(10 * time.Millisecond)
+	trans.SetDestinations(1, []string{conn.LocalAddr().String()})
+	ch, err := trans.Activate(1)
+	if err != nil {
+		t.Fatalf("Activating with a priority failed: %v", err)
+	}
+	defer close(ch)
+
+	//next reads the packets until the check is fulfilled
+	next := func(check func(p DataPacket) bool) {
+		t.Helper()
+		buf := make([]byte, 638)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				t.Fatalf("the expected packet was not received: %v", err)
+			}
+			p, err := NewDataPacketRaw(buf[:n])
+			if err == nil && p.CID() == [16]byte{20} && check(p) {
+				return
+			}
+		}
+	}
+	next(func(p DataPacket) bool {
+		return p.Priority() == 150 && p.SourceName() == "global" && !p.PreviewData() &&
+			p.DmxStartCode() == StartCodeDMX
+	})
+
+	//the options are changed while the universe is activated
+	if err := trans.SetUniversePriority(1, 201); err == nil {
+		t.Error("Err was nil! Priority 201 is not allowed")
+	}
+	if err := trans.SetUniversePriority(1, 50); err != nil {
+		t.Fatal(err)
+	}
+	trans.SetUniverseSourceName(1, "universe")
+	trans.SetPreviewData(1, true)
+	trans.SetStartCode(1, 0x17)
+	next(func(p DataPacket) bool {
+		return p.Priority() == 50 && p.SourceName() == "universe" && p.PreviewData() &&
+			p.DmxStartCode() == 0x17
+	})
+	if trans.UniversePriority(1) != 50 || trans.UniversePriority(2) != 150 {
+		t.Errorf("Wrong priorities! Was: %v %v", trans.UniversePriority(1), trans.UniversePriority(2))
+	}
+	if trans.UniverseSourceName(1) != "universe" || trans.UniverseSourceName(2) != "global" {
+		t.Errorf("Wrong source names! Was: %v %v", trans.UniverseSourceName(1), trans.UniverseSourceName(2))
+	}
+	if !trans.IsPreviewData(1) || trans.StartCode(1) != 0x17 || trans.StartCode(2) != StartCodeDMX {
+		t.Error("Wrong preview flag or start code")
+	}
+
+	//an empty source name falls back to the source name of the transmitter
+	trans.SetUniverseSourceName(1, "")
+	next(func(p DataPacket) bool { return p.SourceName() == "global" })
+}